@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 // ThreadStatus статус треда
 type ThreadStatus int
 
@@ -23,10 +25,20 @@ type ThreadsMap map[PostID]ThreadStruct
 // PostsMap хранилище постов
 type PostsMap map[PostID]PostStruct
 
+// RepliesMap обратный индекс ответов, ключ - номер поста, на который ответили,
+// значение - номера ответивших постов по возрастанию
+type RepliesMap map[PostID]ThreadPosts
+
 // ThreadStruct хранит метаинформацию о треде и список постов
 type ThreadStruct struct {
 	Status  ThreadStatus
-	Lasthit int64
+	Lasthit int64 // время последнего ответа
+	// число постов, файлов и просмотров по данным списка тредов
+	PostsCount int
+	FilesCount int
+	Views      int
+	// время загрузки треда целиком, нулевое для превью из списка тредов
+	Updated time.Time
 	// список номеров постов, начиная с первого
 	Posts ThreadPosts
 }
@@ -36,7 +48,13 @@ type PostStruct struct {
 	Subject   string
 	Name      string
 	Comment   string
+	Trip      string
+	Files     []string // имена прикрепленных файлов
 	Timestamp int64
+	OP        bool // пост автора треда
+
+	// текст полей без разметки для правил скрытия, общий для копий поста
+	plain *postText
 }
 
 // BoardStruct кеширует треды с разбивкой по доскам
@@ -46,11 +64,15 @@ type BoardStruct struct {
 
 	// Индекс тредов
 	ThreadsIndex ThreadPosts
+	// время загрузки списка тредов
+	Updated time.Time
 
 	// Threads хранит все треды, ключ номер первого поста
 	Threads ThreadsMap
 	// Все посты на этой доске, ключ - номер поста
 	Posts PostsMap
+	// Ответы на посты этой доски
+	Replies RepliesMap
 }
 
 // ImageBoard является корневым хранилищем
@@ -61,4 +83,6 @@ type ImageBoard struct {
 	Categories []string
 	// Разбивка досок по категориям
 	BoardsByCategory map[string][]string
+	// Полнотекстовый индекс загруженных постов, nil - посты не индексируются
+	Index *SearchIndex
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// boardMatches проверяет, подходит ли доска под запрос: "/b/" - доска b,
+// "/b" - доски, идентификатор которых начинается с b, иначе подстрока
+// идентификатора или названия без учета регистра
+func boardMatches(id, name, query string) bool {
+	switch {
+	case query == "":
+		return true
+	case len(query) > 1 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/"):
+		return id == strings.Trim(query, "/")
+	case strings.HasPrefix(query, "/"):
+		return strings.HasPrefix(id, query[1:])
+	}
+
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(id), query) || strings.Contains(strings.ToLower(name), query)
+}
+
+// buildBoardTree строит дерево досок: избранное, затем категории. Если задан
+// запрос, в дереве остаются только подходящие доски, их категории раскрыты,
+// выбрана первая найденная доска. Возвращает узел избранного
+func buildBoardTree(lst *tview.TreeView, ib *ImageBoard, fav *Favorites, query string) *tview.TreeNode {
+	root := tview.NewTreeNode("Доски")
+	lst.SetRoot(root).SetCurrentNode(root).SetTopLevel(0)
+
+	favorites := tview.NewTreeNode("Избранное").SetExpanded(true)
+	root.AddChild(favorites)
+	fillFavorites(favorites, fav, ib, query)
+
+	var first *tview.TreeNode
+	for _, bcat := range ib.Categories {
+		cat := tview.NewTreeNode(bcat).SetExpanded(query != "")
+
+		for _, b := range ib.BoardsByCategory[bcat] {
+			if !boardMatches(b, ib.Boards[b].Name, query) {
+				continue
+			}
+
+			brd := tview.NewTreeNode(fmt.Sprintf("/%v/", b))
+			brd.SetReference(b)
+			cat.AddChild(brd)
+
+			if first == nil {
+				first = brd
+			}
+		}
+
+		if query == "" || len(cat.GetChildren()) > 0 {
+			root.AddChild(cat)
+		}
+	}
+
+	if query != "" {
+		if children := favorites.GetChildren(); len(children) > 0 {
+			first = children[0]
+		}
+		if first != nil {
+			lst.SetCurrentNode(first)
+		}
+	}
+
+	return favorites
+}
+
+// selectBoardNode выбирает в дереве узел доски в ее категории и раскрывает
+// категорию, возвращает false, если доски нет в дереве
+func selectBoardNode(lst *tview.TreeView, boardID string, favorites *tview.TreeNode) bool {
+	found := false
+	lst.GetRoot().Walk(func(node, parent *tview.TreeNode) bool {
+		if found {
+			return false
+		}
+		if node.GetReference() == boardID && parent != favorites {
+			parent.SetExpanded(true)
+			lst.SetCurrentNode(node)
+			found = true
+		}
+		return true
+	})
+
+	return found
+}
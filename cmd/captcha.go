@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	// форматы картинок капчи
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Captcha задача капчи
+type Captcha struct {
+	Type    string // тип капчи сайта, например 2chcaptcha
+	ID      string
+	Image   []byte // картинка в исходном формате
+	Numeric bool   // ответ состоит из цифр
+}
+
+// CaptchaProvider способ прохождения капчи при отправке поста
+type CaptchaProvider interface {
+	// Challenge получает капчу для поста в тред thread доски board, nil -
+	// капча не нужна
+	Challenge(board string, thread PostID) (*Captcha, error)
+	// Fields возвращает поля поста с ответом answer на капчу c, c может быть nil
+	Fields(c *Captcha, answer string) map[string]string
+	// Invalidate сбрасывает сохраненный вход, когда сайт не принял пост без
+	// капчи, следующий Challenge входит заново
+	Invalidate()
+}
+
+// captchaProviders способы прохождения капчи по названию из настроек
+var captchaProviders = map[string]func(cfg PostingConfig) CaptchaProvider{
+	"2chcaptcha": func(cfg PostingConfig) CaptchaProvider { return dvachCaptcha{} },
+	"passcode":   func(cfg PostingConfig) CaptchaProvider { return &passcodeCaptcha{passcode: cfg.Passcode} },
+	"none":       func(cfg PostingConfig) CaptchaProvider { return noCaptcha{} },
+}
+
+// fetchData загружает данные GET-запросом, ответ с кодом, отличным от 200,
+// считается ошибкой
+func fetchData(url string) ([]byte, error) {
+	req := RequestEntry{Time: time.Now(), Method: http.MethodGet, URL: url}
+	defer func() {
+		req.Duration = time.Since(req.Time)
+		logger.Request(req)
+	}()
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		req.Err = err
+		return nil, err
+	}
+	defer resp.Body.Close()
+	req.Status = resp.StatusCode
+
+	data, err := ioutil.ReadAll(resp.Body)
+	req.Size = len(data)
+	if err != nil {
+		req.Err = err
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	return data, nil
+}
+
+// noCaptcha посты отправляются без капчи, например на локальный сервер
+type noCaptcha struct{}
+
+func (noCaptcha) Challenge(board string, thread PostID) (*Captcha, error) {
+	return nil, nil
+}
+
+func (noCaptcha) Fields(c *Captcha, answer string) map[string]string {
+	return nil
+}
+
+func (noCaptcha) Invalidate() {}
+
+// _captchaID ответ сайта на запрос капчи: Result 1 - капча нужна, 2 - не
+// нужна, 0 - ошибка
+type _captchaID struct {
+	Result      int    `json:"result"`
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Input       string `json:"input"`
+	Description string `json:"description"`
+}
+
+// dvachCaptcha капча сайта: номер задачи и картинка с цифрами
+type dvachCaptcha struct{}
+
+func (dvachCaptcha) Challenge(board string, thread PostID) (*Captcha, error) {
+	data, err := fetchData(fmt.Sprintf("%v/api/captcha/2chcaptcha/id?board=%v&thread=%v",
+		baseURL, url.QueryEscape(board), thread))
+	if err != nil {
+		return nil, err
+	}
+
+	var res _captchaID
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v: %q", err, jsonContext(data, 0))
+	}
+
+	switch res.Result {
+	case 1:
+	case 2:
+		return nil, nil
+	default:
+		if res.Description != "" {
+			return nil, fmt.Errorf("captcha: %v", res.Description)
+		}
+		return nil, fmt.Errorf("captcha: unexpected result %v", res.Result)
+	}
+	if res.ID == "" {
+		return nil, errors.New("captcha: empty id")
+	}
+
+	c := &Captcha{Type: "2chcaptcha", ID: res.ID, Numeric: res.Input == "numeric"}
+	c.Image, err = fetchData(fmt.Sprintf("%v/api/captcha/2chcaptcha/show?id=%v", baseURL, url.QueryEscape(res.ID)))
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (dvachCaptcha) Fields(c *Captcha, answer string) map[string]string {
+	if c == nil {
+		return map[string]string{"captcha_type": "2chcaptcha"}
+	}
+
+	return map[string]string{
+		"captcha_type":    c.Type,
+		c.Type + "_id":    c.ID,
+		c.Type + "_value": answer,
+	}
+}
+
+func (dvachCaptcha) Invalidate() {}
+
+// passcodeCaptcha вход по пасскоду, посты отправляются без капчи. Сайт
+// запоминает вход в куках, вход выполняется перед первым постом
+type passcodeCaptcha struct {
+	passcode string
+	loggedIn bool
+}
+
+// _passcodeResult ответ сайта на вход по пасскоду
+type _passcodeResult struct {
+	Result      int    `json:"result"`
+	Description string `json:"description"`
+}
+
+func (p *passcodeCaptcha) Challenge(board string, thread PostID) (*Captcha, error) {
+	if p.loggedIn {
+		return nil, nil
+	}
+
+	form := url.Values{"task": {"auth"}, "usercode": {p.passcode}}
+	data, err := postData(fmt.Sprintf("%v/makaba/makaba.fcgi?json=1", baseURL),
+		"application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	var res _passcodeResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v: %q", err, jsonContext(data, 0))
+	}
+	if res.Result != 1 {
+		if res.Description != "" {
+			return nil, fmt.Errorf("passcode: %v", res.Description)
+		}
+		return nil, errors.New("passcode: login failed")
+	}
+
+	p.loggedIn = true
+	Infof("logged in with passcode")
+	return nil, nil
+}
+
+func (p *passcodeCaptcha) Fields(c *Captcha, answer string) map[string]string {
+	return nil
+}
+
+// Invalidate забывает вход, сессия пасскода на сайте могла закончиться
+func (p *passcodeCaptcha) Invalidate() {
+	if p.loggedIn {
+		Infof("passcode session is not accepted, logging in again before the next post")
+	}
+	p.loggedIn = false
+}
+
+// renderCaptcha рисует картинку капчи шириной не больше width символов
+// полублоками ▀: верхний пиксель цветом текста, нижний цветом фона. В
+// монохромном режиме пиксели темнее среднего рисуются символами блоков
+func renderCaptcha(data []byte, width int, mono bool) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	b := img.Bounds()
+	if b.Empty() {
+		return "", errors.New("empty image")
+	}
+	if width <= 0 || width > b.Dx() {
+		width = b.Dx()
+	}
+	// символ в два раза выше своей ширины, поэтому строка - два ряда пикселей
+	height := (b.Dy()*width/b.Dx() + 1) / 2 * 2
+	if height < 2 {
+		height = 2
+	}
+
+	pixel := func(x, y int) color.RGBA {
+		r, g, bl, a := img.At(b.Min.X+x*b.Dx()/width, b.Min.Y+y*b.Dy()/height).RGBA()
+		// прозрачное рисуется белым
+		white := 0xffff - a
+		return color.RGBA{uint8((r + white) >> 8), uint8((g + white) >> 8), uint8((bl + white) >> 8), 0xff}
+	}
+
+	var sb strings.Builder
+	if mono {
+		gray := make([]int, width*height)
+		total := 0
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				c := pixel(x, y)
+				gray[y*width+x] = (299*int(c.R) + 587*int(c.G) + 114*int(c.B)) / 1000
+				total += gray[y*width+x]
+			}
+		}
+		mean := total / len(gray)
+
+		blocks := []string{" ", "▄", "▀", "█"}
+		for y := 0; y < height; y += 2 {
+			for x := 0; x < width; x++ {
+				i := 0
+				if gray[y*width+x] < mean {
+					i += 2
+				}
+				if gray[(y+1)*width+x] < mean {
+					i++
+				}
+				sb.WriteString(blocks[i])
+			}
+			sb.WriteByte('\n')
+		}
+		return sb.String(), nil
+	}
+
+	hex := func(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+	for y := 0; y < height; y += 2 {
+		last := ""
+		for x := 0; x < width; x++ {
+			tag := fmt.Sprintf("[%v:%v]", hex(pixel(x, y)), hex(pixel(x, y+1)))
+			if tag != last {
+				sb.WriteString(tag)
+				last = tag
+			}
+			sb.WriteString("▀")
+		}
+		sb.WriteString("[-:-]\n")
+	}
+
+	return sb.String(), nil
+}
+
+// openCaptcha сохраняет картинку капчи во временный файл и открывает ее
+// программой viewer или программой системы по умолчанию, возвращает путь
+// файла
+func openCaptcha(c *Captcha, viewer string) (string, error) {
+	ext := ".png"
+	switch http.DetectContentType(c.Image) {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/gif":
+		ext = ".gif"
+	}
+
+	fl, err := ioutil.TempFile("", "boarding-captcha-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fl.Write(c.Image); err != nil {
+		fl.Close()
+		os.Remove(fl.Name())
+		return "", err
+	}
+	if err := fl.Close(); err != nil {
+		os.Remove(fl.Name())
+		return "", err
+	}
+
+	if viewer == "" {
+		viewer = "xdg-open"
+		if runtime.GOOS == "darwin" {
+			viewer = "open"
+		}
+	}
+	args := strings.Fields(viewer)
+	cmd := exec.Command(args[0], append(args[1:], fl.Name())...)
+	if err := cmd.Start(); err != nil {
+		os.Remove(fl.Name())
+		return "", err
+	}
+	// программа просмотра работает независимо, ее код возврата не нужен
+	go cmd.Wait()
+
+	return fl.Name(), nil
+}
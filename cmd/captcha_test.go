@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testImage картинка 2x2: красный и синий пиксели сверху, прозрачный и
+// черный снизу
+func testImage(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	img.Set(1, 0, color.NRGBA{0, 0, 0xff, 0xff})
+	img.Set(0, 1, color.NRGBA{0, 0, 0, 0})
+	img.Set(1, 1, color.NRGBA{0, 0, 0, 0xff})
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDvachCaptchaChallenge(t *testing.T) {
+	imageData := testImage(t)
+
+	tests := []struct {
+		name    string
+		id      string
+		captcha *Captcha
+		err     string
+	}{
+		{"image", `{"result":1,"type":"2chcaptcha","id":"abc","input":"numeric"}`,
+			&Captcha{Type: "2chcaptcha", ID: "abc", Image: imageData, Numeric: true}, ""},
+		{"not needed", `{"result":2}`, nil, ""},
+		{"error", `{"result":0,"description":"Вы постите слишком быстро"}`, nil, "captcha: Вы постите слишком быстро"},
+		{"unknown result", `{"result":3}`, nil, "captcha: unexpected result 3"},
+		{"no id", `{"result":1,"type":"2chcaptcha"}`, nil, "captcha: empty id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/captcha/2chcaptcha/id", func(w http.ResponseWriter, r *http.Request) {
+				if q := r.URL.Query(); q.Get("board") != "b" || q.Get("thread") != "123" {
+					t.Errorf("unexpected query %v", r.URL.RawQuery)
+				}
+				w.Write([]byte(tt.id))
+			})
+			mux.HandleFunc("/api/captcha/2chcaptcha/show", func(w http.ResponseWriter, r *http.Request) {
+				if tt.captcha == nil {
+					t.Errorf("image is requested")
+				}
+				if id := r.URL.Query().Get("id"); id != "abc" {
+					t.Errorf("image of captcha %q is requested", id)
+				}
+				w.Write(imageData)
+			})
+			withServer(t, mux)
+
+			c, err := dvachCaptcha{}.Challenge("b", 123)
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(c, tt.captcha) {
+				t.Errorf("captcha = %+v, want %+v", c, tt.captcha)
+			}
+		})
+	}
+}
+
+func TestPasscodeLogin(t *testing.T) {
+	logins := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/makaba/makaba.fcgi", func(w http.ResponseWriter, r *http.Request) {
+		logins++
+		if r.Method != http.MethodPost || r.FormValue("task") != "auth" {
+			t.Errorf("unexpected login request %v %v", r.Method, r.Form)
+		}
+		if r.FormValue("usercode") != "secret" {
+			w.Write([]byte(`{"result":0,"description":"Неверный пасскод"}`))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "passcode_auth", Value: "token", Path: "/"})
+		w.Write([]byte(`{"result":1}`))
+	})
+	mux.HandleFunc("/makaba/posting.fcgi", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("passcode_auth"); err != nil || c.Value != "token" {
+			t.Errorf("post is sent without passcode cookie")
+		}
+		w.Write([]byte(`{"Error":null,"Status":"OK","Num":125}`))
+	})
+	withServer(t, mux)
+
+	p := &passcodeCaptcha{passcode: "secret"}
+	for i := 0; i < 2; i++ {
+		c, err := p.Challenge("b", 123)
+		if c != nil || err != nil {
+			t.Fatalf("Challenge() = %v, %v, want nil, nil", c, err)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("%v logins, want 1", logins)
+	}
+	if _, err := SubmitPost(PostForm{Board: "b", Thread: 123, Comment: "Текст"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// после неудачного входа вход повторяется при следующем посте
+	p = &passcodeCaptcha{passcode: "wrong"}
+	for i := 0; i < 2; i++ {
+		_, err := p.Challenge("b", 123)
+		if err == nil || err.Error() != "passcode: Неверный пасскод" {
+			t.Fatalf("error = %v, want passcode error", err)
+		}
+	}
+	if logins != 3 {
+		t.Errorf("%v logins, want 3", logins)
+	}
+}
+
+func TestPasscodeSessionExpired(t *testing.T) {
+	logins, posts := 0, 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/makaba/makaba.fcgi", func(w http.ResponseWriter, r *http.Request) {
+		logins++
+		w.Write([]byte(`{"result":1}`))
+	})
+	// первый пост приходит после окончания сессии
+	mux.HandleFunc("/makaba/posting.fcgi", func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		if posts == 1 {
+			w.Write([]byte(`{"Error":-5,"Reason":"Капча невалидна"}`))
+			return
+		}
+		w.Write([]byte(`{"Error":null,"Status":"OK","Num":125}`))
+	})
+	withServer(t, mux)
+
+	// отправка поста как в интерфейсе: вход, пост, при отказе вход сбрасывается
+	var p CaptchaProvider = &passcodeCaptcha{passcode: "secret"}
+	send := func() error {
+		if _, err := p.Challenge("b", 123); err != nil {
+			return err
+		}
+		_, err := SubmitPost(PostForm{Board: "b", Thread: 123, Comment: "Текст"})
+		var pe *PostingError
+		if errors.As(err, &pe) && pe.CaptchaRequired() {
+			p.Invalidate()
+		}
+		return err
+	}
+
+	if err := send(); err == nil || err.Error() != "server error -5: Капча невалидна" {
+		t.Fatalf("error = %v, want captcha error", err)
+	}
+	if err := send(); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 2 {
+		t.Errorf("%v logins, want 2", logins)
+	}
+}
+
+func TestRenderCaptcha(t *testing.T) {
+	data := testImage(t)
+
+	// прозрачный пиксель рисуется белым
+	got, err := renderCaptcha(data, captchaWidth, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[#ff0000:#ffffff]▀[#0000ff:#000000]▀[-:-]\n"; got != want {
+		t.Errorf("color image = %q, want %q", got, want)
+	}
+
+	// красный и синий темнее среднего, белый светлее
+	got, err = renderCaptcha(data, captchaWidth, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "▀█\n"; got != want {
+		t.Errorf("mono image = %q, want %q", got, want)
+	}
+
+	// картинка уменьшается до ширины, строка - два ряда пикселей
+	got, err = renderCaptcha(data, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "▀\n"; got != want {
+		t.Errorf("scaled image = %q, want %q", got, want)
+	}
+
+	if _, err := renderCaptcha([]byte("not an image"), captchaWidth, false); err == nil {
+		t.Error("invalid image is rendered")
+	}
+}
+
+func TestConfigDumpHidesPasscode(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Posting.Passcode = "secret"
+
+	var buf bytes.Buffer
+	if err := cfg.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if dump := buf.String(); strings.Contains(dump, "secret") || !strings.Contains(dump, `passcode = "***"`) {
+		t.Errorf("passcode is not hidden:\n%v", dump)
+	}
+	if cfg.Posting.Passcode != "secret" {
+		t.Errorf("Dump changed passcode to %q", cfg.Posting.Passcode)
+	}
+}
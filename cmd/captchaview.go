@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// captchaWidth наибольшая ширина картинки капчи в символах
+const captchaWidth = 72
+
+// CaptchaView картинка капчи и поле ответа
+type CaptchaView struct {
+	*tview.Flex
+	image *tview.TextView
+	form  *tview.Form
+
+	captcha *Captcha
+	answer  string
+	mono    bool
+	viewer  string
+
+	// временный файл картинки, открытой во внешней программе
+	openedFile string
+
+	answerFunc func(c *Captcha, answer string)
+	reloadFunc func()
+	cancelFunc func()
+}
+
+// NewCaptchaView создает панель капчи, viewer - программа просмотра картинки
+func NewCaptchaView(viewer string) *CaptchaView {
+	v := &CaptchaView{
+		Flex:   tview.NewFlex().SetDirection(tview.FlexRow),
+		image:  tview.NewTextView().SetDynamicColors(true).SetWrap(false),
+		form:   tview.NewForm(),
+		viewer: viewer,
+	}
+
+	v.SetBorder(true).SetTitle(" Капча ")
+	v.form.SetCancelFunc(v.cancel)
+	v.AddItem(v.image, 0, 1, false).
+		AddItem(v.form, 5, 0, true)
+
+	return v
+}
+
+// SetMono включает рисование картинки без цветов
+func (v *CaptchaView) SetMono(mono bool) *CaptchaView {
+	v.mono = mono
+	if v.captcha != nil {
+		v.render()
+	}
+	return v
+}
+
+// SetAnswerFunc задает обработчик ответа на капчу
+func (v *CaptchaView) SetAnswerFunc(handler func(c *Captcha, answer string)) *CaptchaView {
+	v.answerFunc = handler
+	return v
+}
+
+// SetReloadFunc задает обработчик запроса другой капчи
+func (v *CaptchaView) SetReloadFunc(handler func()) *CaptchaView {
+	v.reloadFunc = handler
+	return v
+}
+
+// SetCancelFunc задает обработчик закрытия панели
+func (v *CaptchaView) SetCancelFunc(handler func()) *CaptchaView {
+	v.cancelFunc = handler
+	return v
+}
+
+// Show показывает капчу c и очищает ответ
+func (v *CaptchaView) Show(c *Captcha) {
+	v.captcha = c
+	v.answer = ""
+	v.render()
+
+	// Enter в поле ответа отправляет пост
+	field := tview.NewInputField().SetLabel("Ответ").SetFieldWidth(20)
+	if c.Numeric {
+		field.SetAcceptanceFunc(func(text string, ch rune) bool { return ch >= '0' && ch <= '9' })
+	}
+	field.SetChangedFunc(func(text string) { v.answer = text })
+	field.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			v.submit()
+		}
+	})
+
+	v.form.Clear(true)
+	v.form.AddFormItem(field).
+		AddButton("Отправить", v.submit).
+		AddButton("Открыть", v.open).
+		AddButton("Другая", func() {
+			if v.reloadFunc != nil {
+				v.reloadFunc()
+			}
+		}).
+		AddButton("Отмена", v.cancel)
+}
+
+// render рисует картинку капчи, если картинку не удалось разобрать,
+// предлагается открыть ее во внешней программе
+func (v *CaptchaView) render() {
+	text, err := renderCaptcha(v.captcha.Image, captchaWidth, v.mono)
+	if err != nil {
+		Warnf("captcha image: %v", err)
+		text = "Картинку не удалось показать, кнопка \"Открыть\" открывает ее в программе просмотра"
+	}
+	v.image.SetText(text).ScrollToBeginning()
+}
+
+// open открывает картинку капчи во внешней программе
+func (v *CaptchaView) open() {
+	if v.captcha == nil {
+		return
+	}
+
+	v.removeOpened()
+	name, err := openCaptcha(v.captcha, v.viewer)
+	if err != nil {
+		Warnf("can't open captcha image: %v", err)
+		return
+	}
+	v.openedFile = name
+}
+
+// Close удаляет временный файл картинки
+func (v *CaptchaView) Close() {
+	v.removeOpened()
+	v.captcha = nil
+}
+
+func (v *CaptchaView) removeOpened() {
+	if v.openedFile != "" {
+		os.Remove(v.openedFile)
+		v.openedFile = ""
+	}
+}
+
+func (v *CaptchaView) submit() {
+	if v.answerFunc != nil {
+		v.answerFunc(v.captcha, v.answer)
+	}
+}
+
+func (v *CaptchaView) cancel() {
+	if v.cancelFunc != nil {
+		v.cancelFunc()
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// maxIndexPages ограничивает число загружаемых страниц индекса доски
+const maxIndexPages = 30
+
+// структура каталога доски: все треды, у каждого только первый пост
+type _catalog struct {
+	Threads []struct {
+		_post
+		PostsCount int `json:"posts_count"`
+		FilesCount int `json:"files_count"`
+	} `json:"threads"`
+}
+
+// updateCatalog загружает каталог доски, возвращает номера тредов в порядке
+// каталога. Ошибка означает, что каталог недоступен и нужно загрузить индекс
+func (ib *ImageBoard) updateCatalog(ID string) (ThreadPosts, error) {
+	data, err := GetCatalog(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var c _catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	// вместо каталога с ошибкой HTTP приходит пустой объект
+	if len(c.Threads) == 0 {
+		return nil, errors.New("catalog is empty")
+	}
+
+	index := make(ThreadPosts, 0, len(c.Threads))
+	for _, th := range c.Threads {
+		num, err := th.Num.Int64()
+		if err != nil {
+			return nil, err
+		}
+
+		ib.updatePost(ID, PostID(num), th._post)
+		ib.updateThreadInfo(ID, PostID(num), ThreadStruct{
+			Lasthit:    th.Lasthit,
+			PostsCount: th.PostsCount,
+			FilesCount: th.FilesCount,
+			Views:      th.Views,
+			Posts:      ThreadPosts{PostID(num)},
+		})
+		index = append(index, PostID(num))
+	}
+
+	return index, nil
+}
+
+// ThreadOrder порядок тредов в списке
+type ThreadOrder string
+
+// Порядки тредов
+const (
+	OrderBump    ThreadOrder = "bump"    // по последнему ответу
+	OrderCreated ThreadOrder = "created" // новые треды сначала
+	OrderPosts   ThreadOrder = "posts"   // по числу постов
+	OrderViews   ThreadOrder = "views"   // по числу просмотров
+)
+
+// threadOrders порядки тредов в порядке переключения и их названия
+var threadOrders = []struct {
+	Order ThreadOrder
+	Name  string
+}{
+	{OrderBump, "по последнему ответу"},
+	{OrderCreated, "по времени создания"},
+	{OrderPosts, "по числу постов"},
+	{OrderViews, "по числу просмотров"},
+}
+
+// findThreadOrder возвращает номер порядка тредов
+func findThreadOrder(order ThreadOrder) (int, bool) {
+	for i, o := range threadOrders {
+		if o.Order == order {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// SortThreads упорядочивает список тредов доски, треды с одинаковым ключом
+// остаются в порядке сайта
+func (ib *ImageBoard) SortThreads(ID string, order ThreadOrder) {
+	board := ib.Boards[ID]
+	threads := board.Threads
+
+	var less func(a, b PostID) bool
+	switch order {
+	case OrderBump:
+		less = func(a, b PostID) bool { return threads[a].Lasthit > threads[b].Lasthit }
+	case OrderCreated:
+		less = func(a, b PostID) bool { return a > b }
+	case OrderPosts:
+		less = func(a, b PostID) bool { return threads[a].PostsCount > threads[b].PostsCount }
+	case OrderViews:
+		less = func(a, b PostID) bool { return threads[a].Views > threads[b].Views }
+	default:
+		return
+	}
+
+	index := board.ThreadsIndex
+	sort.SliceStable(index, func(i, j int) bool { return less(index[i], index[j]) })
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// commandInfo описание команды строки команд
+type commandInfo struct {
+	name string
+	args string
+	help string
+}
+
+// commands команды строки команд
+var commands = []commandInfo{
+	{"open", "доска [тред]", "открыть список тредов доски или тред: open b 12345, open /b/12345"},
+	{"goto", "пост", "перейти к посту открытого треда или другого треда доски"},
+	{"refresh", "", "обновить тред или список тредов"},
+	{"watch", "", "следить за тредом"},
+	{"export", "md [файл]", "сохранить тред, по умолчанию в доска-тред.md"},
+	{"search", "текст|/выражение/", "искать в загруженных постах доски"},
+	{"find", "слова", "искать слова в любой форме во всех когда-либо загруженных постах"},
+	{"quit", "", "выход"},
+}
+
+// Command разобранная строка команд
+type Command struct {
+	Name string
+	Args []string
+}
+
+// ParseCommand разбирает строку команд, имя команды можно сократить до
+// однозначного начала
+func ParseCommand(line string) (Command, error) {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), ":"))
+	if len(fields) == 0 {
+		return Command{}, errors.New("empty command")
+	}
+
+	var found []string
+	for _, c := range commands {
+		if c.name == fields[0] {
+			found = []string{c.name}
+			break
+		}
+		if strings.HasPrefix(c.name, fields[0]) {
+			found = append(found, c.name)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return Command{}, fmt.Errorf("unknown command %q", fields[0])
+	case 1:
+		return Command{Name: found[0], Args: fields[1:]}, nil
+	default:
+		return Command{}, fmt.Errorf("ambiguous command %q: %v", fields[0], strings.Join(found, ", "))
+	}
+}
+
+// ParseThreadRef разбирает доску и номер треда: "b 12345", "/b/12345",
+// "/b/" или "b", номер треда 0, если он не указан
+func ParseThreadRef(args []string) (string, PostID, error) {
+	ref := strings.Join(args, "/")
+	parts := strings.FieldsFunc(ref, func(r rune) bool { return r == '/' })
+
+	switch len(parts) {
+	case 1:
+		return parts[0], 0, nil
+	case 2:
+		num, err := ParsePostID(parts[1])
+		return parts[0], num, err
+	default:
+		return "", 0, fmt.Errorf("bad thread reference %q, use board and thread number", strings.Join(args, " "))
+	}
+}
+
+// ParsePostID разбирает номер поста, допускается запись >>12345
+func ParsePostID(s string) (PostID, error) {
+	num, err := strconv.ParseInt(strings.TrimPrefix(s, ">>"), 10, 64)
+	if err != nil || num <= 0 {
+		return 0, fmt.Errorf("bad post number %q", s)
+	}
+
+	return PostID(num), nil
+}
+
+// completeCommand дополняет имя команды, доску для open и формат для export
+func completeCommand(text string, boards []string) []string {
+	fields := strings.Fields(text)
+	typingArg := strings.HasSuffix(text, " ")
+
+	var variants []string
+	switch {
+	case len(fields) == 0:
+		return nil
+
+	case len(fields) == 1 && !typingArg:
+		for _, c := range commands {
+			if strings.HasPrefix(c.name, fields[0]) {
+				variants = append(variants, c.name)
+			}
+		}
+
+	case len(fields) == 1 || (len(fields) == 2 && !typingArg):
+		prefix := ""
+		if len(fields) == 2 {
+			prefix = fields[1]
+		}
+
+		var values []string
+		switch fields[0] {
+		case "open":
+			// доска записывается как b или /b/
+			slash := strings.HasPrefix(prefix, "/")
+			prefix = strings.Trim(prefix, "/")
+			for _, id := range boards {
+				if slash {
+					id = "/" + id + "/"
+				}
+				values = append(values, id)
+			}
+			if slash {
+				prefix = "/" + prefix
+			}
+		case "export":
+			values = exportFormats
+		}
+
+		for _, v := range values {
+			if strings.HasPrefix(v, prefix) {
+				variants = append(variants, fields[0]+" "+v)
+			}
+		}
+	}
+
+	sort.Strings(variants)
+	return variants
+}
+
+// completeLine дополняет строку команд до общего начала вариантов, после
+// единственного варианта добавляется пробел
+func completeLine(text string, boards []string) string {
+	variants := completeCommand(text, boards)
+	switch len(variants) {
+	case 0:
+		return text
+	case 1:
+		return variants[0] + " "
+	}
+
+	prefix := variants[0]
+	for _, v := range variants[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(prefix) < len(text) {
+		return text
+	}
+
+	return prefix
+}
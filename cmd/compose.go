@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// newlineMark показывает перевод строки в однострочном поле комментария
+const newlineMark = "↵"
+
+// commentToField и fieldToComment переводят комментарий в текст поля и обратно
+func commentToField(comment string) string {
+	return strings.ReplaceAll(comment, "\n", newlineMark)
+}
+
+func fieldToComment(text string) string {
+	return strings.ReplaceAll(text, newlineMark, "\n")
+}
+
+// splitFiles разбирает пути файлов, разделенные ";", ~ заменяется домашним
+// каталогом
+func splitFiles(text string) []string {
+	var files []string
+	home, _ := os.UserHomeDir()
+	for _, f := range strings.Split(text, ";") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if home != "" && (f == "~" || strings.HasPrefix(f, "~/")) {
+			f = filepath.Join(home, f[1:])
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// ComposeView форма поста: ответа в тред или нового треда. Черновик
+// сохраняется, пока форма открывается для того же треда
+type ComposeView struct {
+	*tview.Form
+	comment *tview.InputField
+
+	draft PostForm
+	sage  bool
+	files string
+
+	submitFunc func(p PostForm)
+	cancelFunc func()
+	editFunc   func(text string) (string, error)
+}
+
+// NewComposeView создает форму поста
+func NewComposeView() *ComposeView {
+	c := &ComposeView{
+		Form:    tview.NewForm(),
+		comment: tview.NewInputField(),
+	}
+
+	c.SetBorder(true)
+	c.Form.SetCancelFunc(func() {
+		if c.cancelFunc != nil {
+			c.cancelFunc()
+		}
+	})
+
+	c.comment.SetLabel("Комментарий").SetFieldWidth(0)
+	c.comment.SetChangedFunc(func(text string) { c.draft.Comment = fieldToComment(text) })
+	// Enter переходит к следующему полю, Ctrl+J добавляет перевод строки
+	c.comment.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyCtrlJ {
+			c.comment.SetText(c.comment.GetText() + newlineMark)
+			return nil
+		}
+		return event
+	})
+
+	return c
+}
+
+// SetSubmitFunc задает обработчик отправки поста
+func (c *ComposeView) SetSubmitFunc(handler func(p PostForm)) *ComposeView {
+	c.submitFunc = handler
+	return c
+}
+
+// SetCancelFunc задает обработчик закрытия формы
+func (c *ComposeView) SetCancelFunc(handler func()) *ComposeView {
+	c.cancelFunc = handler
+	return c
+}
+
+// SetEditFunc задает редактирование комментария во внешнем редакторе
+func (c *ComposeView) SetEditFunc(handler func(text string) (string, error)) *ComposeView {
+	c.editFunc = handler
+	return c
+}
+
+// Open заполняет форму для ответа в тред thread доски board или нового треда,
+// если thread 0. Черновик другого треда заменяется, имя и e-mail остаются.
+// Если quote не 0, в комментарий добавляется ссылка на пост quote
+func (c *ComposeView) Open(board string, thread, quote PostID) {
+	if c.draft.Board != board || c.draft.Thread != thread {
+		c.draft = PostForm{Board: board, Thread: thread, Name: c.draft.Name, Email: c.draft.Email}
+		c.files = ""
+	}
+	if quote != 0 {
+		link := fmt.Sprintf(">>%v", quote)
+		if !strings.Contains(c.draft.Comment, link) {
+			if c.draft.Comment != "" && !strings.HasSuffix(c.draft.Comment, "\n") {
+				c.draft.Comment += "\n"
+			}
+			c.draft.Comment += link + "\n"
+		}
+	}
+
+	title := fmt.Sprintf(" Новый тред в /%v/ ", board)
+	if thread != 0 {
+		title = fmt.Sprintf(" Ответ в тред /%v/%v ", board, thread)
+	}
+
+	d := &c.draft
+	c.Clear(true).SetTitle(title + "· Ctrl+J - новая строка ")
+	c.AddInputField("Имя", d.Name, 0, nil, func(text string) { d.Name = text }).
+		AddInputField("E-mail", d.Email, 0, nil, func(text string) { d.Email = text }).
+		AddCheckbox("Sage", c.sage, func(checked bool) { c.sage = checked })
+	if thread == 0 {
+		c.AddInputField("Тема", d.Subject, 0, nil, func(text string) { d.Subject = text })
+	}
+	c.comment.SetText(commentToField(d.Comment))
+	c.AddFormItem(c.comment).
+		AddInputField("Файлы через ;", c.files, 0, nil, func(text string) { c.files = text })
+
+	c.AddButton("Отправить", c.submit).
+		AddButton("Редактор", c.edit).
+		AddButton("Отмена", func() {
+			if c.cancelFunc != nil {
+				c.cancelFunc()
+			}
+		})
+}
+
+// Post возвращает пост из заполненной формы
+func (c *ComposeView) Post() PostForm {
+	p := c.draft
+	if c.sage {
+		p.Email = "sage"
+	}
+	p.Files = splitFiles(c.files)
+	return p
+}
+
+// ResetDraft очищает черновик после отправки, имя и e-mail остаются
+func (c *ComposeView) ResetDraft() {
+	c.draft = PostForm{Name: c.draft.Name, Email: c.draft.Email}
+	c.files = ""
+}
+
+func (c *ComposeView) submit() {
+	if c.submitFunc != nil {
+		c.submitFunc(c.Post())
+	}
+}
+
+// edit открывает комментарий во внешнем редакторе
+func (c *ComposeView) edit() {
+	if c.editFunc == nil {
+		return
+	}
+
+	text, err := c.editFunc(c.draft.Comment)
+	if err != nil {
+		Warnf("editor: %v", err)
+		return
+	}
+	c.comment.SetText(commentToField(strings.TrimRight(text, "\n")))
+}
+
+// editInEditor редактирует текст во внешнем редакторе $VISUAL или $EDITOR
+// через временный файл
+func editInEditor(text string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	fl, err := ioutil.TempFile("", "boarding-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(fl.Name())
+
+	if _, err := fl.WriteString(text); err != nil {
+		fl.Close()
+		return "", err
+	}
+	if err := fl.Close(); err != nil {
+		return "", err
+	}
+
+	// в $EDITOR могут быть аргументы
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], fl.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(fl.Name())
+	return string(data), err
+}
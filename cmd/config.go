@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell"
+)
+
+// Duration промежуток времени, в файле настроек записывается как "30s", "1m"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText разбирает промежуток времени из файла настроек
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+// MarshalText записывает промежуток времени для файла настроек
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// LogConfig секция [log] файла настроек
+type LogConfig struct {
+	Level string `toml:"level"` // пусто - журнал не пишется
+	File  string `toml:"file"`  // пусто - файл по умолчанию
+}
+
+// LayoutConfig секция [layout]: относительные размеры панелей
+type LayoutConfig struct {
+	Boards  int `toml:"boards"`  // ширина дерева досок
+	Content int `toml:"content"` // ширина колонки со списком тредов и тредом
+	Threads int `toml:"threads"` // высота списка тредов
+	Thread  int `toml:"thread"`  // высота треда
+}
+
+// ColorsConfig секция [colors]: цвета текста тредов вместо цветов темы,
+// имена цветов или #rrggbb
+type ColorsConfig struct {
+	Link      string `toml:"link"`
+	Greentext string `toml:"greentext"`
+	Spoiler   string `toml:"spoiler"`
+}
+
+// PostingConfig секция [posting]: прохождение капчи при отправке постов
+type PostingConfig struct {
+	Captcha  string `toml:"captcha"`  // 2chcaptcha, passcode или none
+	Passcode string `toml:"passcode"` // для captcha = "passcode", можно задать в BOARDING_PASSCODE
+	Viewer   string `toml:"viewer"`   // программа просмотра картинки капчи, пусто - программа системы
+}
+
+// Config настройки программы
+type Config struct {
+	BaseURL       string        `toml:"base_url"`       // адрес сайта
+	Fetcher       string        `toml:"fetcher"`        // http или stub
+	StubDir       string        `toml:"stub_dir"`       // каталог с JSON для fetcher = "stub"
+	Timeout       Duration      `toml:"timeout"`        // таймаут запросов
+	WatchInterval Duration      `toml:"watch_interval"` // период обновления отслеживаемых тредов
+	Theme         string        `toml:"theme"`          // встроенная тема
+	ColorMode     string        `toml:"color_mode"`     // auto, truecolor, 256 или mono
+	StartBoard    string        `toml:"start_board"`    // доска, открываемая при запуске
+	ThreadOrder   ThreadOrder   `toml:"thread_order"`   // bump, created, posts или views
+	ThreadList    string        `toml:"thread_list"`    // compact или detailed
+	Log           LogConfig     `toml:"log"`
+	Layout        LayoutConfig  `toml:"layout"`
+	Colors        ColorsConfig  `toml:"colors"`
+	Keys          KeysConfig    `toml:"keys"`
+	Posting       PostingConfig `toml:"posting"`
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() *Config {
+	return &Config{
+		BaseURL:       "https://2ch.hk",
+		Fetcher:       "http",
+		StubDir:       "data",
+		Timeout:       Duration{30 * time.Second},
+		WatchInterval: Duration{time.Minute},
+		Layout:        LayoutConfig{Boards: 1, Content: 8, Threads: 2, Thread: 5},
+		Theme:         "dark",
+		ColorMode:     ColorModeAuto,
+		ThreadOrder:   OrderBump,
+		ThreadList:    ListDetailed,
+		Keys:          KeysConfig{Preset: "default"},
+		Posting:       PostingConfig{Captcha: "2chcaptcha"},
+	}
+}
+
+// fetchers способы загрузки данных
+var fetchers = map[string]func(url string) ([]byte, error){
+	"http": getJSON,
+	"stub": getJSONStub,
+}
+
+// minWatchInterval не дает слишком часто опрашивать сайт
+const minWatchInterval = 10 * time.Second
+
+// defaultConfigFile возвращает путь $XDG_CONFIG_HOME/boarding/config.toml
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "boarding", "config.toml")
+}
+
+// LoadConfig читает файл настроек поверх настроек по умолчанию. Отсутствие
+// файла не ошибка, если explicit == false, т.е. путь не задан пользователем
+func LoadConfig(filename string, explicit bool) (*Config, error) {
+	cfg := DefaultConfig()
+
+	if filename == "" {
+		return cfg, nil
+	}
+
+	md, err := toml.DecodeFile(filename, cfg)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		return nil, fmt.Errorf("unknown options: %v", strings.Join(keys, ", "))
+	}
+
+	return cfg, nil
+}
+
+// ApplyEnv переопределяет настройки переменными окружения
+func (cfg *Config) ApplyEnv() {
+	if level := os.Getenv("BOARDING_LOG"); level != "" {
+		cfg.Log.Level = level
+	}
+	if passcode := os.Getenv("BOARDING_PASSCODE"); passcode != "" {
+		cfg.Posting.Passcode = passcode
+	}
+}
+
+// ApplyFlags переопределяет настройки флагами, заданными в командной строке
+func (cfg *Config) ApplyFlags(fs *flag.FlagSet) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "base-url":
+			cfg.BaseURL = *baseURLFlag
+		case "fetcher":
+			cfg.Fetcher = *fetcherFlag
+		case "log":
+			cfg.Log.Level = *logLevelFlag
+		case "log-file":
+			cfg.Log.File = *logFileFlag
+		case "keys":
+			cfg.Keys.Preset = *keysFlag
+		case "watch-interval":
+			cfg.WatchInterval.Duration = *watchIntervalFlag
+		case "theme":
+			cfg.Theme = *themeFlag
+		case "color-mode":
+			cfg.ColorMode = *colorModeFlag
+		case "board":
+			cfg.StartBoard = *boardFlag
+		}
+	})
+}
+
+// Validate проверяет настройки, ошибка называет неверный параметр
+func (cfg *Config) Validate() error {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if u, err := url.Parse(cfg.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		fail("base_url: %q is not an http(s) URL", cfg.BaseURL)
+	}
+
+	if _, ok := fetchers[cfg.Fetcher]; !ok {
+		fail("fetcher: unknown fetcher %q, use http or stub", cfg.Fetcher)
+	} else if cfg.Fetcher == "stub" {
+		if info, err := os.Stat(cfg.StubDir); err != nil || !info.IsDir() {
+			fail("stub_dir: %q is not a directory", cfg.StubDir)
+		}
+	}
+
+	if _, ok := captchaProviders[cfg.Posting.Captcha]; !ok {
+		fail("posting.captcha: unknown captcha %q, use 2chcaptcha, passcode or none", cfg.Posting.Captcha)
+	} else if cfg.Posting.Captcha == "passcode" && cfg.Posting.Passcode == "" {
+		fail("posting.passcode: must be set for captcha = \"passcode\"")
+	}
+
+	if cfg.Timeout.Duration <= 0 {
+		fail("timeout: must be positive, got %v", cfg.Timeout)
+	}
+	if cfg.WatchInterval.Duration < minWatchInterval {
+		fail("watch_interval: must be at least %v, got %v", minWatchInterval, cfg.WatchInterval)
+	}
+
+	if cfg.Log.Level != "" {
+		if _, err := ParseLogLevel(cfg.Log.Level); err != nil {
+			fail("log.level: %v", err)
+		}
+	}
+
+	for name, size := range map[string]int{
+		"boards":  cfg.Layout.Boards,
+		"content": cfg.Layout.Content,
+		"threads": cfg.Layout.Threads,
+		"thread":  cfg.Layout.Thread,
+	} {
+		if size <= 0 {
+			fail("layout.%v: must be positive, got %v", name, size)
+		}
+	}
+
+	if _, ok := findTheme(cfg.Theme); !ok {
+		fail("theme: unknown theme %q, use one of %v", cfg.Theme, themeNames())
+	}
+	switch cfg.ColorMode {
+	case ColorModeAuto, ColorModeTrueColor, ColorMode256, ColorModeMono:
+	default:
+		fail("color_mode: unknown mode %q, use auto, truecolor, 256 or mono", cfg.ColorMode)
+	}
+
+	if _, ok := findThreadOrder(cfg.ThreadOrder); !ok {
+		fail("thread_order: unknown order %q, use bump, created, posts or views", cfg.ThreadOrder)
+	}
+
+	if cfg.ThreadList != ListCompact && cfg.ThreadList != ListDetailed {
+		fail("thread_list: unknown mode %q, use compact or detailed", cfg.ThreadList)
+	}
+
+	for name, color := range map[string]string{
+		"link":      cfg.Colors.Link,
+		"greentext": cfg.Colors.Greentext,
+		"spoiler":   cfg.Colors.Spoiler,
+	} {
+		if color == "" {
+			continue
+		}
+		if _, err := parseColor(color); err != nil {
+			fail("colors.%v: %v", name, err)
+		}
+	}
+
+	if _, err := NewKeymap(cfg.Keys.Preset, cfg.Keys.Bindings); err != nil {
+		fail("keys: %v", err)
+	}
+
+	if len(errs) > 0 {
+		// порядок сообщений не должен зависеть от обхода map
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, "\n"))
+	}
+
+	return nil
+}
+
+// parseColor разбирает имя цвета или #rrggbb
+func parseColor(name string) (tcell.Color, error) {
+	color := tcell.GetColor(name)
+	if color == tcell.ColorDefault && name != "default" {
+		return color, fmt.Errorf("unknown color %q", name)
+	}
+
+	return color, nil
+}
+
+// Dump записывает действующие настройки в формате файла настроек, пасскод
+// скрывается
+func (cfg *Config) Dump(w io.Writer) error {
+	dump := *cfg
+	if dump.Posting.Passcode != "" {
+		dump.Posting.Passcode = "***"
+	}
+
+	return toml.NewEncoder(w).Encode(dump)
+}
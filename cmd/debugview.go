@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// DebugView отладочная панель со списком последних запросов и записей журнала
+type DebugView struct {
+	*tview.TextView
+	visible bool
+}
+
+// NewDebugView создает отладочную панель
+func NewDebugView() *DebugView {
+	dv := &DebugView{TextView: tview.NewTextView().SetDynamicColors(true)}
+	dv.SetBorder(true).SetTitle(" Отладка (F12) ")
+
+	return dv
+}
+
+// Update обновляет содержимое панели, если она показана
+func (dv *DebugView) Update() {
+	if dv.visible {
+		dv.SetText(renderDebugInfo())
+	}
+}
+
+// levelColors цвета записей журнала в панели
+var levelColors = map[LogLevel]string{
+	LevelDebug: "gray",
+	LevelInfo:  "white",
+	LevelWarn:  "yellow",
+	LevelError: "red",
+}
+
+// renderDebugInfo формирует текст панели, последние записи сверху
+func renderDebugInfo() string {
+	entries, requests := logger.Recent()
+
+	var sb strings.Builder
+
+	sb.WriteString("[yellow::b]Запросы[-::-]\n")
+	for i := len(requests) - 1; i >= 0; i-- {
+		r := requests[i]
+
+		status := fmt.Sprint(r.Status)
+		if r.Err != nil {
+			status = "[red]" + tview.Escape(r.Err.Error()) + "[-]"
+		}
+
+		fmt.Fprintf(&sb, "%v %-4v %v %8v %8d %v\n", r.Time.Format("15:04:05"), r.Method,
+			status, r.Duration.Round(time.Millisecond), r.Size, tview.Escape(r.URL))
+	}
+
+	sb.WriteString("\n[yellow::b]Журнал[-::-]\n")
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		fmt.Fprintf(&sb, "%v [%v]%-5v %v[-]\n",
+			e.Time.Format("15:04:05"), levelColors[e.Level], e.Level, tview.Escape(e.Message))
+	}
+
+	return sb.String()
+}
+
+// centered размещает p по центру экрана поверх других страниц
+func centered(p tview.Primitive) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, 0, 4, true).
+			AddItem(nil, 0, 1, false), 0, 4, true).
+		AddItem(nil, 0, 1, false)
+}
@@ -3,8 +3,8 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"sort"
+	"time"
 )
 
 // Описание получаемой структуры JSON, указаны только нужные поля
@@ -47,6 +47,7 @@ func (ib *ImageBoard) FetchCategories() {
 			b.Name = br.Name
 			b.Posts = make(PostsMap)
 			b.Threads = make(ThreadsMap)
+			b.Replies = make(RepliesMap)
 
 			ib.Boards[br.ID] = b
 
@@ -59,53 +60,134 @@ func (ib *ImageBoard) FetchCategories() {
 	return // boardCatalog
 }
 
+// BoardIDs возвращает идентификаторы всех досок по алфавиту
+func (ib *ImageBoard) BoardIDs() []string {
+	ids := make([]string, 0, len(ib.Boards))
+	for id := range ib.Boards {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
 // структура отдельного поста
 type _post struct {
 	Num       json.Number `json:"num"`
 	Comment   string      `json:"comment"`
 	Name      string      `json:"name"`
 	Subject   string      `json:"subject"`
+	Trip      string      `json:"trip"`
+	Files     []_file     `json:"files"`
 	Timestamp int64       `json:"timestamp"`
+	Op        int         `json:"op"`
+	Lasthit   int64       `json:"lasthit"` // время последнего ответа, у первого поста
+	Views     int         `json:"views"`
+}
+
+// прикрепленный к посту файл
+type _file struct {
+	Name string `json:"name"`
 }
 
 // структура треда
 type _thread struct {
 	Board     string `json:"Board"`
 	PostCount int    `json:"posts_count"`
+	Pages     []int  `json:"pages"` // номера страниц индекса доски
 	Threads   []struct {
-		Posts []_post `json:"posts"`
+		PostsCount int     `json:"posts_count"`
+		FilesCount int     `json:"files_count"`
+		Posts      []_post `json:"posts"`
 	} `json:"threads"`
 }
 
+// jsonContext возвращает участок данных вокруг позиции ошибки разбора
+func jsonContext(data []byte, offset int64) []byte {
+	const radius = 100
+
+	from, to := offset-radius, offset+radius
+	if from < 0 {
+		from = 0
+	}
+	if to > int64(len(data)) {
+		to = int64(len(data))
+	}
+	if from > to {
+		from = to
+	}
+
+	return data[from:to]
+}
+
 // UpdateBoard Обновляет данные по указанной доске, пропавшие, удаленные, обновленный треды будут
-// помечены соответствующим образом
+// помечены соответствующим образом. Возвращает ошибку, если не загрузился ни каталог, ни индекс доски
 // TODO обнаружение пропавших, новых и тп это в планах, пока просто загружаются новые данные
-func (ib *ImageBoard) UpdateBoard(ID string) {
+func (ib *ImageBoard) UpdateBoard(ID string) error {
 	if ib.Boards == nil {
 		panic("ib.Boards uninitialized")
 	}
 
-	data, err := GetThreads(ID)
+	// каталог содержит все треды доски, страницы индекса - запасной вариант
+	tempThreadIndex, err := ib.updateCatalog(ID)
 	if err != nil {
-		panic(err)
+		Warnf("can't load catalog of /%v/, loading index pages: %v", ID, err)
+		if tempThreadIndex, err = ib.updateIndexPages(ID); err != nil {
+			return err
+		}
 	}
 
-	var t _thread
-	if ID == "fur" {
-		var m interface{}
-		json.Unmarshal(data, m)
-		//panic("STOP")
-	}
-	if err := json.Unmarshal(data, &t); err != nil {
-		if e, ok := err.(*json.SyntaxError); ok {
-			ioutil.WriteFile("invalid_json.json", data, 0666)
-			errMsg := fmt.Sprintf("syntax error at byte offset %d", e.Offset)
-			ioutil.WriteFile("invalid_json.txt", []byte(errMsg), 0666)
+	tempBoard := ib.Boards[ID]
+	tempBoard.ThreadsIndex = tempThreadIndex
+	tempBoard.Updated = time.Now()
+	ib.Boards[ID] = tempBoard
+
+	return nil
+}
+
+// updateIndexPages загружает треды со всех страниц индекса доски
+func (ib *ImageBoard) updateIndexPages(ID string) (ThreadPosts, error) {
+	var index ThreadPosts
+	seen := make(map[PostID]bool)
 
+	pages := []int{0}
+	for i := 0; i < len(pages) && i < maxIndexPages; i++ {
+		data, err := GetThreadsPage(ID, pages[i])
+		if err != nil {
+			return nil, err
+		}
+
+		var t _thread
+		if err := json.Unmarshal(data, &t); err != nil {
+			if e, ok := err.(*json.SyntaxError); ok {
+				Debugf("invalid JSON near offset %d: %q", e.Offset, jsonContext(data, e.Offset))
+			}
+			return nil, fmt.Errorf("invalid JSON of board /%v/: %v", ID, err)
+		}
+
+		// список страниц есть в каждой из них, берется из первой
+		if i == 0 {
+			pages = append(pages, t.Pages...)
+		}
+
+		// тред, сдвинувшийся за время загрузки на следующую страницу, уже в списке
+		threads, err := ib.updateIndexPage(ID, &t)
+		if err != nil {
+			return nil, err
+		}
+		for _, thNum := range threads {
+			if !seen[thNum] {
+				seen[thNum] = true
+				index = append(index, thNum)
+			}
 		}
-		panic(err)
 	}
 
+	return index, nil
+}
+
+// updateIndexPage сохраняет треды страницы индекса, возвращает их номера
+func (ib *ImageBoard) updateIndexPage(ID string, t *_thread) (ThreadPosts, error) {
 	// номера тредов (первых постов)
 
 	//ib.Boards[ID].Threads := make([]ThreadStruct, 0, len(t.Threads))
@@ -120,7 +202,7 @@ func (ib *ImageBoard) UpdateBoard(ID string) {
 
 		thNum, err := th.Posts[0].Num.Int64()
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("invalid thread number on board /%v/: %v", ID, err)
 		}
 		var tempThread ThreadStruct
 		tempThread.Posts = make(ThreadPosts, 0, len(th.Posts))
@@ -129,67 +211,122 @@ func (ib *ImageBoard) UpdateBoard(ID string) {
 		for _, ps := range th.Posts {
 			num, err := ps.Num.Int64()
 			if err != nil {
-				panic(err)
+				return nil, fmt.Errorf("invalid post number in thread /%v/%v: %v", ID, thNum, err)
 			}
 
 			tempThread.Posts = append(tempThread.Posts, PostID(num))
-			ib.updatePost(ID, ps)
+			ib.updatePost(ID, PostID(thNum), ps)
 		}
-		ib.Boards[ID].Threads[PostID(thNum)] = tempThread
 
+		// в индексе posts_count - число постов, не попавших в превью
+		tempThread.PostsCount = th.PostsCount + len(th.Posts)
+		tempThread.FilesCount = th.FilesCount
+		tempThread.Lasthit = th.Posts[0].Lasthit
+		tempThread.Views = th.Posts[0].Views
+		ib.updateThreadInfo(ID, PostID(thNum), tempThread)
 	}
-	tempBoard := ib.Boards[ID]
-	tempBoard.ThreadsIndex = tempThreadIndex
-	ib.Boards[ID] = tempBoard
+
+	return tempThreadIndex, nil
 }
 
-// UpdateThread обновляет данные указанного треда
-func (ib *ImageBoard) UpdateThread(ID string, num PostID) {
-	data, err := GetThread(ID, num)
+// updateThreadInfo сохраняет тред из списка тредов, полностью загруженный
+// тред не заменяется превью, у него обновляются только сведения о треде
+func (ib *ImageBoard) updateThreadInfo(ID string, num PostID, thread ThreadStruct) {
+	if old, ok := ib.Boards[ID].Threads[num]; ok && len(old.Posts) > len(thread.Posts) {
+		thread.Posts = old.Posts
+		thread.Updated = old.Updated
+	}
+
+	ib.Boards[ID].Threads[num] = thread
+}
 
+// UpdateThread обновляет данные указанного треда, возвращает ошибку загрузки
+// или разбора, а также ошибку, если тред удален или не найден
+func (ib *ImageBoard) UpdateThread(ID string, num PostID) error {
+	data, err := GetThread(ID, num)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
+	return ib.ApplyThread(ID, num, data)
+}
+
+// ApplyThread сохраняет тред num из загруженного JSON data. Загрузка может
+// идти в другой горутине, данные доски меняются только здесь
+func (ib *ImageBoard) ApplyThread(ID string, num PostID, data []byte) error {
 	var t _thread
 	if err := json.Unmarshal(data, &t); err != nil {
-		panic(err)
+		return fmt.Errorf("invalid JSON of thread /%v/%v: %v", ID, num, err)
+	}
+
+	if len(t.Threads) == 0 || len(t.Threads[0].Posts) == 0 {
+		return fmt.Errorf("thread /%v/%v not found", ID, num)
 	}
 
 	thNum, err := t.Threads[0].Posts[0].Num.Int64()
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("invalid number of thread /%v/%v: %v", ID, num, err)
 	}
 
-	var tempThread ThreadStruct
+	// сведения из списка тредов сохраняются до его обновления
+	tempThread := ib.Boards[ID].Threads[PostID(thNum)]
 	tempThread.Posts = make(ThreadPosts, 0, len(t.Threads[0].Posts))
+	tempThread.Updated = time.Now()
 
 	for _, ps := range t.Threads[0].Posts {
 		num, err := ps.Num.Int64()
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("invalid post number in thread /%v/%v: %v", ID, thNum, err)
 		}
 
 		tempThread.Posts = append(tempThread.Posts, PostID(num))
-		ib.updatePost(ID, ps)
+		ib.updatePost(ID, PostID(thNum), ps)
+
+		if ps.Timestamp > tempThread.Lasthit {
+			tempThread.Lasthit = ps.Timestamp
+		}
 	}
+	tempThread.PostsCount = len(tempThread.Posts)
 	ib.Boards[ID].Threads[PostID(thNum)] = tempThread
+
+	return nil
 }
 
-func (ib *ImageBoard) updatePost(ID string, p _post) {
+// updatePost сохраняет пост треда threadID
+func (ib *ImageBoard) updatePost(ID string, threadID PostID, p _post) {
 	num, err := p.Num.Int64()
 	if err != nil {
 		panic(err)
 	}
 
-	if _, ok := ib.Boards[ID].Posts[PostID(num)]; ok {
-		//fmt.Printf("Post %v exists\n", num)
+	// ссылки и текст индексируются только у новых или измененных постов
+	old, ok := ib.Boards[ID].Posts[PostID(num)]
+	if !ok || old.Comment != p.Comment {
+		ib.indexReplies(ID, PostID(num), old.Comment, p.Comment)
+		if ib.Index != nil {
+			ib.Index.Add(ID, threadID, PostID(num), p.Comment, p.Timestamp)
+		}
+	}
+
+	// текст без разметки разбирается заново только у измененных постов
+	plain := old.plain
+	if !ok || old.Subject != p.Subject || old.Name != p.Name || old.Trip != p.Trip || old.Comment != p.Comment {
+		plain = &postText{}
+	}
+
+	var files []string
+	for _, f := range p.Files {
+		files = append(files, f.Name)
 	}
 
 	ib.Boards[ID].Posts[PostID(num)] = PostStruct{
 		Subject:   p.Subject,
 		Name:      p.Name,
 		Comment:   p.Comment,
+		Trip:      p.Trip,
+		Files:     files,
 		Timestamp: p.Timestamp,
+		OP:        p.Op != 0,
+		plain:     plain,
 	}
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/2chboarding/boarding/richtext"
+)
+
+// exportFormats форматы выгрузки треда
+var exportFormats = []string{"md"}
+
+// exportFilename возвращает имя файла выгрузки по умолчанию
+func exportFilename(boardID string, threadID PostID, format string) string {
+	return fmt.Sprintf("%v-%v.%v", boardID, threadID, format)
+}
+
+// ExportMarkdown записывает тред в формате Markdown: заголовок со ссылкой на
+// тред, затем посты по порядку с номером, именем и датой
+func (ib *ImageBoard) ExportMarkdown(w io.Writer, boardID string, threadID PostID) error {
+	board := ib.Boards[boardID]
+	thread, ok := board.Threads[threadID]
+	if !ok {
+		return fmt.Errorf("thread /%v/%v is not loaded", boardID, threadID)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# /%v/ %v\n\n%v/%v/res/%v.html\n", boardID, threadSubject(board, threadID), baseURL, boardID, threadID)
+
+	for _, postID := range thread.Posts {
+		post := board.Posts[postID]
+
+		fmt.Fprintf(bw, "\n## %v · %v · %v", postID, richtext.Parse(post.Name).Markdown(),
+			time.Unix(post.Timestamp, 0).Format("02.01.2006 15:04:05"))
+		if post.OP || postID == threadID {
+			bw.WriteString(" · OP")
+		}
+		fmt.Fprintf(bw, "\n\n%v\n", richtext.Parse(post.Comment).Markdown())
+	}
+
+	return bw.Flush()
+}
+
+// ExportThread сохраняет тред в файл filename в формате format
+func (ib *ImageBoard) ExportThread(filename, format, boardID string, threadID PostID) error {
+	if format != "md" {
+		return fmt.Errorf("unknown export format %q, use md", format)
+	}
+
+	fl, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := ib.ExportMarkdown(fl, boardID, threadID); err != nil {
+		fl.Close()
+		return err
+	}
+
+	return fl.Close()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rivo/tview"
+)
+
+// Favorites избранные доски в порядке добавления, сохраняются между запусками
+type Favorites struct {
+	Boards []string `json:"boards"`
+}
+
+// favoritesFile файл избранного в каталоге состояния
+const favoritesFile = "favorites.json"
+
+// LoadFavorites загружает избранное
+func LoadFavorites() (*Favorites, error) {
+	fav := &Favorites{}
+	if err := loadState(favoritesFile, fav); err != nil {
+		return &Favorites{}, err
+	}
+
+	return fav, nil
+}
+
+// Save записывает избранное
+func (f *Favorites) Save() error {
+	return saveState(favoritesFile, f)
+}
+
+// Contains проверяет, есть ли доска в избранном
+func (f *Favorites) Contains(boardID string) bool {
+	for _, b := range f.Boards {
+		if b == boardID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Toggle добавляет доску в избранное или убирает ее, возвращает true, если
+// доска добавлена
+func (f *Favorites) Toggle(boardID string) bool {
+	for i, b := range f.Boards {
+		if b == boardID {
+			f.Boards = append(f.Boards[:i], f.Boards[i+1:]...)
+			return false
+		}
+	}
+
+	f.Boards = append(f.Boards, boardID)
+	return true
+}
+
+// fillFavorites заполняет узел избранного в дереве досок досками, подходящими
+// под запрос query, доски, которых нет в каталоге, пропускаются
+func fillFavorites(node *tview.TreeNode, fav *Favorites, ib *ImageBoard, query string) {
+	node.ClearChildren()
+
+	for _, b := range fav.Boards {
+		board, ok := ib.Boards[b]
+		if !ok || !boardMatches(b, board.Name, query) {
+			continue
+		}
+
+		brd := tview.NewTreeNode(fmt.Sprintf("/%v/", b))
+		brd.SetReference(b)
+		node.AddChild(brd)
+	}
+}
@@ -5,27 +5,62 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+// адрес сайта, http-клиент и каталог заглушек задаются настройками
+var (
+	baseURL    = "https://2ch.hk"
+	httpClient = &http.Client{Timeout: 30 * time.Second}
+	stubDir    = "data"
+
+	// данные загружаются из заглушек, отправка постов недоступна
+	stubFetcher = false
+)
+
+// SetupFetcher настраивает загрузку данных
+func SetupFetcher(cfg *Config) {
+	baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	httpClient.Timeout = cfg.Timeout.Duration
+	// куки хранят вход по пасскоду
+	httpClient.Jar, _ = cookiejar.New(nil)
+	stubDir = cfg.StubDir
+	stubFetcher = cfg.Fetcher == "stub"
+	getterFunc = fetchers[cfg.Fetcher]
+}
+
 func getJSON(url string) ([]byte, error) {
-	//log.Printf("Getting %v ...", url)
-	resp, err := http.Get(url)
+	req := RequestEntry{Time: time.Now(), Method: http.MethodGet, URL: url}
+	defer func() {
+		req.Duration = time.Since(req.Time)
+		logger.Request(req)
+	}()
+
+	resp, err := httpClient.Get(url)
 
 	if err != nil {
+		req.Err = err
 		return nil, err
 	}
 
 	defer resp.Body.Close()
-	//defer log.Printf("Done\n")
+	req.Status = resp.StatusCode
 
 	// При ошибке вернем пустой JSON
 	// TODO сделать нормальную обработку ошибок
 	if resp.StatusCode != 200 {
+		Warnf("GET %v: unexpected status %v", url, resp.Status)
 		return []byte(`{}`), nil
 	}
 
-	return ioutil.ReadAll(resp.Body)
+	data, err := ioutil.ReadAll(resp.Body)
+	req.Size = len(data)
+	req.Err = err
+
+	return data, err
 }
 
 func getJSONStub(url string) ([]byte, error) {
@@ -34,17 +69,22 @@ func getJSONStub(url string) ([]byte, error) {
 	var filename string
 
 	if strings.Contains(url, "get_board") {
-		filename = "data/boards.json"
+		filename = "boards.json"
 	} else if strings.Contains(url, "index.json") {
-		filename = "data/board_index.json"
+		filename = "board_index.json"
+	} else if strings.Contains(url, "catalog.json") {
+		filename = "board_catalog.json"
 	} else if strings.Contains(url, "/res/") {
-		filename = "data/full_thread.json"
+		filename = "full_thread.json"
+	} else if strings.HasSuffix(url, ".json") {
+		// остальные страницы индекса
+		filename = "board_index.json"
 	}
 
 	if filename == "" {
 		return []byte{}, errors.New("Invalid filename")
 	}
-	return ioutil.ReadFile(filename)
+	return ioutil.ReadFile(filepath.Join(stubDir, filename))
 }
 
 //var getterFunc = getJSONStub
@@ -52,19 +92,32 @@ var getterFunc = getJSON
 
 // GetBoardsCatalog загружает данные с сайта
 func GetBoardsCatalog() ([]byte, error) {
-	uri := fmt.Sprintf("https://2ch.hk/makaba/mobile.fcgi?task=get_boards")
+	uri := fmt.Sprintf("%v/makaba/mobile.fcgi?task=get_boards", baseURL)
 	return getterFunc(uri)
 }
 
 // GetThreads load json from given boards containing list of threads (first page)
 func GetThreads(boardID string) ([]byte, error) {
-	url := fmt.Sprintf("https://2ch.hk/%v/index.json", boardID)
-	//url := fmt.Sprintf("https://2ch.hk/%v/catalog.json", boardID)
+	return GetThreadsPage(boardID, 0)
+}
+
+// GetThreadsPage загружает страницу page индекса доски, первая страница - index.json
+func GetThreadsPage(boardID string, page int) ([]byte, error) {
+	url := fmt.Sprintf("%v/%v/index.json", baseURL, boardID)
+	if page > 0 {
+		url = fmt.Sprintf("%v/%v/%v.json", baseURL, boardID, page)
+	}
+	return getterFunc(url)
+}
+
+// GetCatalog загружает каталог со всеми тредами доски
+func GetCatalog(boardID string) ([]byte, error) {
+	url := fmt.Sprintf("%v/%v/catalog.json", baseURL, boardID)
 	return getterFunc(url)
 }
 
 // GetThread получает полный тред с номером num с доски boardID
 func GetThread(boardID string, num PostID) ([]byte, error) {
-	uri := fmt.Sprintf("https://2ch.hk/%v/res/%v.json", boardID, num)
+	uri := fmt.Sprintf("%v/%v/res/%v.json", baseURL, boardID, num)
 	return getterFunc(uri)
 }
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/2chboarding/boarding/richtext"
+	"github.com/2chboarding/boarding/stem"
+)
+
+// maxIndexedPosts наибольшее число постов в сохраненном индексе, при
+// сохранении отбрасываются самые старые посты
+var maxIndexedPosts = 100000
+
+// maxSearchHits наибольшее число находок полнотекстового поиска
+const maxSearchHits = 500
+
+// indexedPost проиндексированный пост, текст хранится для описания находок
+type indexedPost struct {
+	Board     string `json:"board"`
+	Thread    PostID `json:"thread"`
+	Post      PostID `json:"post"`
+	Timestamp int64  `json:"timestamp"`
+	Text      string `json:"text"` // текст поста без разметки
+}
+
+// postKey пост доски
+type postKey struct {
+	board string
+	post  PostID
+}
+
+// SearchIndex полнотекстовый индекс постов: для основы каждого слова - посты,
+// в которых встречаются его формы, с числом вхождений. Индекс хранит все
+// когда-либо загруженные посты и сохраняется между запусками
+type SearchIndex struct {
+	posts map[postKey]*indexedPost
+	terms map[string]map[postKey]int
+}
+
+// NewSearchIndex создает пустой индекс
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		posts: make(map[postKey]*indexedPost),
+		terms: make(map[string]map[postKey]int),
+	}
+}
+
+// indexWord слово текста и его положение
+type indexWord struct {
+	stem     string
+	from, to int
+}
+
+// indexWords разбивает текст на слова из букв и цифр, слова из одного
+// символа пропускаются
+func indexWords(text string) []indexWord {
+	var words []indexWord
+
+	start := -1
+	for i, r := range text + " " {
+		letter := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case letter && start < 0:
+			start = i
+		case !letter && start >= 0:
+			if word := text[start:i]; utf8.RuneCountInString(word) > 1 {
+				words = append(words, indexWord{stem.Stem(stem.Normalize(word)), start, i})
+			}
+			start = -1
+		}
+	}
+
+	return words
+}
+
+// Len возвращает число проиндексированных постов
+func (ix *SearchIndex) Len() int {
+	return len(ix.posts)
+}
+
+// Add индексирует пост, измененный пост индексируется заново
+func (ix *SearchIndex) Add(boardID string, threadID, postID PostID, comment string, timestamp int64) {
+	ix.add(&indexedPost{boardID, threadID, postID, timestamp, richtext.Parse(comment).PlainText()})
+}
+
+func (ix *SearchIndex) add(post *indexedPost) {
+	key := postKey{post.Board, post.Post}
+	if old, ok := ix.posts[key]; ok {
+		if old.Text == post.Text {
+			return
+		}
+		ix.remove(key)
+	}
+
+	ix.posts[key] = post
+	for _, w := range indexWords(post.Text) {
+		if ix.terms[w.stem] == nil {
+			ix.terms[w.stem] = make(map[postKey]int)
+		}
+		ix.terms[w.stem][key]++
+	}
+}
+
+// remove убирает пост из индекса
+func (ix *SearchIndex) remove(key postKey) {
+	for _, w := range indexWords(ix.posts[key].Text) {
+		delete(ix.terms[w.stem], key)
+		if len(ix.terms[w.stem]) == 0 {
+			delete(ix.terms, w.stem)
+		}
+	}
+	delete(ix.posts, key)
+}
+
+// Search ищет посты, в которых есть формы всех слов запроса. Находки
+// упорядочены по числу вхождений слов, затем новые сначала
+func (ix *SearchIndex) Search(query string) ([]SearchHit, error) {
+	terms := make(map[string]bool)
+	for _, w := range indexWords(query) {
+		terms[w.stem] = true
+	}
+	if len(terms) == 0 {
+		return nil, errors.New("query has no words to search")
+	}
+
+	// посты с наименее частым словом проверяются на остальные слова
+	var rarest map[postKey]int
+	for term := range terms {
+		if rarest == nil || len(ix.terms[term]) < len(rarest) {
+			rarest = ix.terms[term]
+		}
+	}
+
+	type scored struct {
+		post  *indexedPost
+		score int
+	}
+	var found []scored
+	for key := range rarest {
+		score := 0
+		for term := range terms {
+			n := ix.terms[term][key]
+			if n == 0 {
+				score = 0
+				break
+			}
+			score += n
+		}
+		if score > 0 {
+			found = append(found, scored{ix.posts[key], score})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].score != found[j].score {
+			return found[i].score > found[j].score
+		}
+		return found[i].post.Timestamp > found[j].post.Timestamp
+	})
+	if len(found) > maxSearchHits {
+		found = found[:maxSearchHits]
+	}
+
+	hits := make([]SearchHit, 0, len(found))
+	for _, f := range found {
+		p := f.post
+		snippet := highlightSnippet(p.Text, 0, 0)
+		for _, w := range indexWords(p.Text) {
+			if terms[w.stem] {
+				snippet = highlightSnippet(p.Text, w.from, w.to)
+				break
+			}
+		}
+		hits = append(hits, SearchHit{p.Board, p.Thread, p.Post, snippet})
+	}
+
+	return hits, nil
+}
+
+// defaultIndexFile возвращает путь $XDG_STATE_HOME/boarding/index.json
+func defaultIndexFile() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "index.json"), nil
+}
+
+// savedIndex формат файла индекса, слова индексируются при загрузке
+type savedIndex struct {
+	Posts []*indexedPost `json:"posts"`
+}
+
+// LoadSearchIndex загружает индекс из файла, отсутствие файла не ошибка
+func LoadSearchIndex(filename string) (*SearchIndex, error) {
+	ix := NewSearchIndex()
+
+	fl, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return ix, err
+	}
+	defer fl.Close()
+
+	var saved savedIndex
+	if err := json.NewDecoder(bufio.NewReader(fl)).Decode(&saved); err != nil {
+		return ix, err
+	}
+
+	for _, p := range saved.Posts {
+		ix.add(p)
+	}
+
+	return ix, nil
+}
+
+// Save записывает в файл не больше maxIndexedPosts самых новых постов
+func (ix *SearchIndex) Save(filename string) error {
+	saved := savedIndex{Posts: make([]*indexedPost, 0, len(ix.posts))}
+	for _, p := range ix.posts {
+		saved.Posts = append(saved.Posts, p)
+	}
+	sort.Slice(saved.Posts, func(i, j int) bool { return saved.Posts[i].Timestamp > saved.Posts[j].Timestamp })
+	if len(saved.Posts) > maxIndexedPosts {
+		saved.Posts = saved.Posts[:maxIndexedPosts]
+	}
+
+	return writeFileAtomic(filename, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(saved)
+	})
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/2chboarding/boarding/stem"
+)
+
+// termPosts возвращает номера постов, в которых есть формы слова word
+func termPosts(ix *SearchIndex, word string) []PostID {
+	var posts []PostID
+	for key := range ix.terms[stem.Stem(stem.Normalize(word))] {
+		posts = append(posts, key.post)
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i] < posts[j] })
+	return posts
+}
+
+// hitPosts возвращает номера постов находок по порядку
+func hitPosts(hits []SearchHit) []PostID {
+	var posts []PostID
+	for _, h := range hits {
+		posts = append(posts, h.Post)
+	}
+	return posts
+}
+
+func TestSearchIndexAdd(t *testing.T) {
+	ix := NewSearchIndex()
+	ix.Add("b", 1, 1, "Коты любят <strong>рыбу</strong>", 100)
+	ix.Add("b", 1, 2, "Кот съел рыбу, а рыба съела кота", 200)
+	ix.Add("po", 3, 2, "Другая доска, тот же номер", 300)
+
+	if ix.Len() != 3 {
+		t.Fatalf("Len() = %v, want 3", ix.Len())
+	}
+	if got := termPosts(ix, "кот"); !reflect.DeepEqual(got, []PostID{1, 2}) {
+		t.Errorf("posts of кот = %v, want [1 2]", got)
+	}
+	if n := ix.terms[stem.Stem("рыба")][postKey{"b", 2}]; n != 2 {
+		t.Errorf("рыба occurs %v times in post 2, want 2", n)
+	}
+
+	// повторное добавление того же текста ничего не меняет
+	ix.Add("b", 1, 1, "Коты любят <strong>рыбу</strong>", 100)
+	if n := ix.terms[stem.Stem("рыба")][postKey{"b", 1}]; n != 1 {
+		t.Errorf("рыба occurs %v times in re-added post 1, want 1", n)
+	}
+
+	// в измененном посте старые слова больше не находятся
+	ix.Add("b", 1, 1, "Коты любят молоко", 100)
+	if ix.Len() != 3 {
+		t.Errorf("Len() = %v after edit, want 3", ix.Len())
+	}
+	if got := termPosts(ix, "рыба"); !reflect.DeepEqual(got, []PostID{2}) {
+		t.Errorf("posts of рыба after edit = %v, want [2]", got)
+	}
+	if got := termPosts(ix, "молоко"); !reflect.DeepEqual(got, []PostID{1}) {
+		t.Errorf("posts of молоко after edit = %v, want [1]", got)
+	}
+
+	// слова, которых больше нет ни в одном посте, убираются
+	ix.remove(postKey{"b", 1})
+	if _, ok := ix.terms[stem.Stem("молоко")]; ok {
+		t.Errorf("term молоко is kept after removal of the only post")
+	}
+	if got := termPosts(ix, "кот"); !reflect.DeepEqual(got, []PostID{2}) {
+		t.Errorf("posts of кот after removal = %v, want [2]", got)
+	}
+	if ix.Len() != 2 {
+		t.Errorf("Len() = %v after removal, want 2", ix.Len())
+	}
+}
+
+func TestSearchIndexSearch(t *testing.T) {
+	ix := NewSearchIndex()
+	ix.Add("b", 1, 1, "Коты любят рыбу", 100)
+	ix.Add("b", 1, 2, "Кот съел рыбу, а рыба съела кота", 200)
+	ix.Add("b", 1, 3, "Рыба плавает", 300)
+	ix.Add("b", 4, 4, "Кот спит", 400)
+	ix.Add("b", 4, 5, "Рыбу ловят сетью", 500)
+
+	tests := []struct {
+		query string
+		posts []PostID
+	}{
+		// больше вхождений выше, при равенстве новые сначала
+		{"рыба", []PostID{2, 5, 3, 1}},
+		{"коты", []PostID{2, 4, 1}},
+		// нужны все слова запроса
+		{"кот рыба", []PostID{2, 1}},
+		{"кот сеть", nil},
+		{"жираф", nil},
+		// слова из одного символа не ищутся
+		{"а рыба", []PostID{2, 5, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		hits, err := ix.Search(tt.query)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.query, err)
+			continue
+		}
+		if got := hitPosts(hits); !reflect.DeepEqual(got, tt.posts) {
+			t.Errorf("%q: posts = %v, want %v", tt.query, got, tt.posts)
+		}
+	}
+
+	for _, query := range []string{"", "а, и"} {
+		if _, err := ix.Search(query); err == nil {
+			t.Errorf("%q: query without words is accepted", query)
+		}
+	}
+}
+
+func TestSearchIndexHits(t *testing.T) {
+	ix := NewSearchIndex()
+	ix.Add("b", 10, 11, "Сегодня <em>утром</em> на улице видел кота", 100)
+	ix.Add("b", 10, 12, "Очень длинное начало поста перед тем, как появится кот", 200)
+
+	hits, err := ix.Search("кот")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// выделяется первое найденное слово, длинное начало сокращается
+	want := []SearchHit{
+		{"b", 10, 12, "…поста перед тем, как появится [::r]кот[::-]"},
+		{"b", 10, 11, "Сегодня утром на улице видел [::r]кота[::-]"},
+	}
+	if !reflect.DeepEqual(hits, want) {
+		t.Errorf("hits = %v, want %v", hits, want)
+	}
+}
+
+func TestSearchIndexSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boarding-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "index.json")
+
+	// отсутствие файла не ошибка
+	ix, err := LoadSearchIndex(filename)
+	if err != nil || ix.Len() != 0 {
+		t.Fatalf("LoadSearchIndex() of missing file = %v posts, %v", ix.Len(), err)
+	}
+
+	ix.Add("b", 1, 1, "Коты любят рыбу", 100)
+	ix.Add("b", 1, 2, "Кот съел рыбу, а рыба съела кота", 200)
+	ix.Add("po", 3, 3, "Рыба плавает", 300)
+	ix.Add("po", 3, 4, "Кот спит", 400)
+
+	if err := ix.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSearchIndex(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != ix.Len() {
+		t.Errorf("loaded %v posts, want %v", loaded.Len(), ix.Len())
+	}
+	for _, query := range []string{"рыба", "кот", "кот рыба", "плавает"} {
+		want, _ := ix.Search(query)
+		got, _ := loaded.Search(query)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: loaded index hits = %v, want %v", query, got, want)
+		}
+	}
+
+	// сохраняются только самые новые посты
+	defer func(max int) { maxIndexedPosts = max }(maxIndexedPosts)
+	maxIndexedPosts = 2
+
+	if err := ix.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadSearchIndex(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 2 {
+		t.Errorf("loaded %v posts, want 2", loaded.Len())
+	}
+	hits, _ := loaded.Search("кот")
+	if got := hitPosts(hits); !reflect.DeepEqual(got, []PostID{4}) {
+		t.Errorf("posts of кот in cut index = %v, want [4]", got)
+	}
+	hits, _ = loaded.Search("рыба")
+	if got := hitPosts(hits); !reflect.DeepEqual(got, []PostID{3}) {
+		t.Errorf("posts of рыба in cut index = %v, want [3]", got)
+	}
+}
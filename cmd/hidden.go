@@ -0,0 +1,117 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/2chboarding/boarding/richtext"
+)
+
+// HiddenItem скрытый вручную тред списка тредов или пост треда
+type HiddenItem struct {
+	Post   PostID    `json:"post"`
+	Thread bool      `json:"thread,omitempty"` // тред скрыт в списке тредов
+	Title  string    `json:"title"`            // тема треда или начало поста
+	Time   time.Time `json:"time"`
+}
+
+// HiddenItems скрытые вручную треды и посты по доскам, сохраняются между запусками
+type HiddenItems struct {
+	Boards map[string][]HiddenItem `json:"boards"`
+}
+
+// hiddenFile файл скрытого в каталоге состояния
+const hiddenFile = "hidden.json"
+
+// LoadHidden загружает скрытое
+func LoadHidden() (*HiddenItems, error) {
+	h := &HiddenItems{}
+	if err := loadState(hiddenFile, h); err != nil {
+		return &HiddenItems{}, err
+	}
+
+	return h, nil
+}
+
+// Save записывает скрытое
+func (h *HiddenItems) Save() error {
+	return saveState(hiddenFile, h)
+}
+
+// Contains проверяет, скрыт ли тред (thread == true) или пост доски
+func (h *HiddenItems) Contains(boardID string, postID PostID, thread bool) bool {
+	for _, item := range h.Boards[boardID] {
+		if item.Post == postID && item.Thread == thread {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Toggle скрывает тред или пост доски, а скрытый возвращает, возвращает true,
+// если item скрыт
+func (h *HiddenItems) Toggle(boardID string, item HiddenItem) bool {
+	if h.Unhide(boardID, item.Post, item.Thread) {
+		return false
+	}
+
+	if h.Boards == nil {
+		h.Boards = make(map[string][]HiddenItem)
+	}
+	h.Boards[boardID] = append(h.Boards[boardID], item)
+	return true
+}
+
+// Unhide возвращает скрытый тред или пост, возвращает false, если он не был скрыт
+func (h *HiddenItems) Unhide(boardID string, postID PostID, thread bool) bool {
+	items := h.Boards[boardID]
+	for i, item := range items {
+		if item.Post == postID && item.Thread == thread {
+			h.Boards[boardID] = append(items[:i], items[i+1:]...)
+			if len(h.Boards[boardID]) == 0 {
+				delete(h.Boards, boardID)
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+// HiddenRef скрытый тред или пост с его доской
+type HiddenRef struct {
+	Board string
+	HiddenItem
+}
+
+// Items возвращает все скрытое, последнее скрытое сначала
+func (h *HiddenItems) Items() []HiddenRef {
+	var refs []HiddenRef
+	for board, items := range h.Boards {
+		for _, item := range items {
+			refs = append(refs, HiddenRef{board, item})
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Time.After(refs[j].Time) })
+	return refs
+}
+
+// hiddenPostTitle возвращает начало текста поста для списка скрытого
+func hiddenPostTitle(post PostStruct) string {
+	return runewidth.Truncate(richtext.Parse(post.Comment).PlainText(), maxSubjectWidth, "…")
+}
+
+// ApplyHidden отмечает скрытые вручную треды доски как скрытые
+func (ib *ImageBoard) ApplyHidden(boardID string, h *HiddenItems) {
+	board := ib.Boards[boardID]
+	for _, item := range h.Boards[boardID] {
+		if thread, ok := board.Threads[item.Post]; ok && item.Thread {
+			thread.Status = Hidden
+			board.Threads[item.Post] = thread
+		}
+	}
+}
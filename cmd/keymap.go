@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// Action именованное действие, на которое назначаются клавиши
+type Action string
+
+// Действия интерфейса
+const (
+	ActNextPanel       Action = "next_panel"
+	ActPrevPanel       Action = "prev_panel"
+	ActScrollUp        Action = "scroll_up"
+	ActScrollDown      Action = "scroll_down"
+	ActPageUp          Action = "page_up"
+	ActPageDown        Action = "page_down"
+	ActTop             Action = "top"
+	ActBottom          Action = "bottom"
+	ActOpen            Action = "open"
+	ActBack            Action = "back"
+	ActNextLink        Action = "next_link"
+	ActPrevLink        Action = "prev_link"
+	ActRefresh         Action = "refresh"
+	ActWatch           Action = "watch"
+	ActFavorite        Action = "favorite"
+	ActFindBoard       Action = "find_board"
+	ActFilterThreads   Action = "filter_threads"
+	ActThreadOrder     Action = "thread_order"
+	ActListDetails     Action = "toggle_details"
+	ActShowHidden      Action = "show_hidden"
+	ActHide            Action = "hide"
+	ActHiddenList      Action = "hidden_list"
+	ActKillfile        Action = "killfile"
+	ActCompose         Action = "compose"
+	ActUnread          Action = "jump_unread"
+	ActToggleTree      Action = "toggle_tree"
+	ActToggleSpoilers  Action = "toggle_spoilers"
+	ActToggleHyphenate Action = "toggle_hyphenation"
+	ActToggleJustify   Action = "toggle_justify"
+	ActNextTab         Action = "next_tab"
+	ActPrevTab         Action = "prev_tab"
+	ActCloseTab        Action = "close_tab"
+	ActNextTheme       Action = "next_theme"
+	ActCommand         Action = "command"
+	ActFind            Action = "find"
+	ActHelp            Action = "help"
+	ActDebug           Action = "debug"
+	ActQuit            Action = "quit"
+)
+
+// actionInfo описание действия для экрана помощи
+type actionInfo struct {
+	Action      Action
+	Description string
+}
+
+// actions все действия в порядке вывода на экране помощи
+var actions = []actionInfo{
+	{ActNextPanel, "следующая панель"},
+	{ActPrevPanel, "предыдущая панель"},
+	{ActScrollUp, "строка вверх"},
+	{ActScrollDown, "строка вниз"},
+	{ActPageUp, "страница вверх"},
+	{ActPageDown, "страница вниз"},
+	{ActTop, "в начало"},
+	{ActBottom, "в конец"},
+	{ActOpen, "открыть доску, тред или ссылку"},
+	{ActBack, "назад: закрыть превью или вернуться к предыдущей панели"},
+	{ActNextLink, "следующая ссылка"},
+	{ActPrevLink, "предыдущая ссылка"},
+	{ActRefresh, "обновить тред или список тредов"},
+	{ActWatch, "следить за тредом"},
+	{ActFavorite, "добавить доску в избранное или убрать из него"},
+	{ActFindBoard, "поиск доски по идентификатору или названию"},
+	{ActFilterThreads, "фильтр списка тредов по теме и тексту ОП-поста"},
+	{ActThreadOrder, "порядок тредов: по последнему ответу, созданию, числу постов, просмотров"},
+	{ActListDetails, "подробный или краткий список тредов"},
+	{ActShowHidden, "показать или скрыть треды и посты, скрытые фильтрами и вручную"},
+	{ActHide, "скрыть тред списка или пост треда, или вернуть скрытый"},
+	{ActHiddenList, "список скрытых вручную тредов и постов"},
+	{ActKillfile, "правила скрытия тредов и постов"},
+	{ActCompose, "написать ответ в открытый тред или новый тред на доске списка"},
+	{ActUnread, "к первому непрочитанному посту"},
+	{ActToggleTree, "дерево ответов / хронология"},
+	{ActToggleSpoilers, "показать спойлеры"},
+	{ActToggleHyphenate, "переносы слов"},
+	{ActToggleJustify, "выравнивание по ширине"},
+	{ActNextTab, "следующая вкладка"},
+	{ActPrevTab, "предыдущая вкладка"},
+	{ActCloseTab, "закрыть вкладку"},
+	{ActNextTheme, "следующая тема оформления"},
+	{ActCommand, "строка команд"},
+	{ActFind, "полнотекстовый поиск по всем загруженным постам"},
+	{ActHelp, "список клавиш"},
+	{ActDebug, "отладочная информация"},
+	{ActQuit, "выход"},
+}
+
+// maxTabActions число вкладок, на которые можно перейти клавишами
+const maxTabActions = 9
+
+// tabAction возвращает действие перехода на вкладку n, начиная с 1
+func tabAction(n int) Action {
+	return Action(fmt.Sprintf("tab_%d", n))
+}
+
+// tabNumber возвращает номер вкладки действия перехода на вкладку
+func tabNumber(action Action) (int, bool) {
+	var n int
+	if _, err := fmt.Sscanf(string(action), "tab_%d", &n); err != nil || n < 1 || n > maxTabActions {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// действия перехода на вкладки по номеру назначены на цифры
+func init() {
+	for n := 1; n <= maxTabActions; n++ {
+		actions = append(actions, actionInfo{tabAction(n), fmt.Sprintf("вкладка %d", n)})
+		keyPresets["default"][tabAction(n)] = []string{fmt.Sprint(n)}
+	}
+}
+
+// actionKeys клавиши, в которые переводятся действия навигации, чтобы их
+// обрабатывали сами виджеты
+var actionKeys = map[Action]tcell.Key{
+	ActScrollUp:   tcell.KeyUp,
+	ActScrollDown: tcell.KeyDown,
+	ActPageUp:     tcell.KeyPgUp,
+	ActPageDown:   tcell.KeyPgDn,
+	ActTop:        tcell.KeyHome,
+	ActBottom:     tcell.KeyEnd,
+	ActOpen:       tcell.KeyEnter,
+	ActNextLink:   tcell.KeyTab,
+	ActPrevLink:   tcell.KeyBacktab,
+}
+
+// Bindings назначения клавиш: действие и список клавиш вида "Ctrl+R", "F5", "j"
+type Bindings map[Action][]string
+
+// keyPresets наборы клавиш, default используется как основа для остальных
+var keyPresets = map[string]Bindings{
+	"default": {
+		ActNextPanel:       {"Right"},
+		ActPrevPanel:       {"Left"},
+		ActScrollUp:        {"Up"},
+		ActScrollDown:      {"Down"},
+		ActPageUp:          {"PgUp"},
+		ActPageDown:        {"PgDn"},
+		ActTop:             {"Home"},
+		ActBottom:          {"End"},
+		ActOpen:            {"Enter"},
+		ActBack:            {"Esc"},
+		ActNextLink:        {"Tab"},
+		ActPrevLink:        {"Shift+Tab"},
+		ActRefresh:         {"F5", "Ctrl+R"},
+		ActWatch:           {"w"},
+		ActFavorite:        {"f"},
+		ActFindBoard:       {"/"},
+		ActFilterThreads:   {"F3"},
+		ActThreadOrder:     {"o"},
+		ActListDetails:     {"d"},
+		ActShowHidden:      {"z"},
+		ActHide:            {"Delete"},
+		ActHiddenList:      {"Z"},
+		ActKillfile:        {"F4"},
+		ActCompose:         {"c"},
+		ActUnread:          {"u"},
+		ActToggleTree:      {"t"},
+		ActToggleSpoilers:  {"s"},
+		ActToggleHyphenate: {"H"},
+		ActToggleJustify:   {"J"},
+		ActNextTab:         {"]"},
+		ActPrevTab:         {"["},
+		ActCloseTab:        {"x"},
+		ActNextTheme:       {"F2"},
+		ActCommand:         {":"},
+		ActFind:            {"F6"},
+		ActHelp:            {"F1", "?"},
+		ActDebug:           {"F12"},
+		ActQuit:            {"Ctrl+Q"},
+	},
+	"vi": {
+		ActNextPanel:  {"Right", "l"},
+		ActPrevPanel:  {"Left", "h"},
+		ActScrollUp:   {"Up", "k"},
+		ActScrollDown: {"Down", "j"},
+		ActPageUp:     {"PgUp", "Ctrl+B"},
+		ActPageDown:   {"PgDn", "Ctrl+F", "Space"},
+		ActTop:        {"Home", "g"},
+		ActBottom:     {"End", "G"},
+		ActNextLink:   {"Tab", "n"},
+		ActPrevLink:   {"Shift+Tab", "N"},
+		ActRefresh:    {"F5", "r"},
+		ActQuit:       {"Ctrl+Q", "q"},
+	},
+	"emacs": {
+		ActNextPanel:  {"Right", "Ctrl+F"},
+		ActPrevPanel:  {"Left", "Ctrl+B"},
+		ActScrollUp:   {"Up", "Ctrl+P"},
+		ActScrollDown: {"Down", "Ctrl+N"},
+		ActPageUp:     {"PgUp", "Alt+v"},
+		ActPageDown:   {"PgDn", "Ctrl+V"},
+		ActTop:        {"Home", "Alt+<"},
+		ActBottom:     {"End", "Alt+>"},
+		ActBack:       {"Esc", "Ctrl+G"},
+		ActRefresh:    {"F5", "Ctrl+R"},
+		ActQuit:       {"Ctrl+Q", "Ctrl+X"},
+	},
+}
+
+// keyID клавиша с модификаторами в виде, пригодном для поиска
+type keyID struct {
+	key tcell.Key
+	ch  rune
+	mod tcell.ModMask
+}
+
+// newKeyID нормализует клавишу: у символов учитывается только Alt, у
+// управляющих символов Ctrl уже входит в код клавиши, у Backtab - Shift
+func newKeyID(key tcell.Key, ch rune, mod tcell.ModMask) keyID {
+	if key == tcell.KeyBacktab {
+		mod &^= tcell.ModShift
+	}
+
+	switch {
+	case key == tcell.KeyRune:
+		mod &= tcell.ModAlt
+	case key < ' ' || key == tcell.KeyDEL:
+		ch = 0
+		mod &^= tcell.ModCtrl
+	default:
+		ch = 0
+	}
+
+	return keyID{key: key, ch: ch, mod: mod}
+}
+
+// keyNames имена специальных клавиш в нижнем регистре
+var keyNames = func() map[string]tcell.Key {
+	names := map[string]tcell.Key{
+		"escape":   tcell.KeyEsc,
+		"return":   tcell.KeyEnter,
+		"pageup":   tcell.KeyPgUp,
+		"pagedown": tcell.KeyPgDn,
+		"del":      tcell.KeyDelete,
+		"ins":      tcell.KeyInsert,
+	}
+	for key, name := range tcell.KeyNames {
+		names[strings.ToLower(name)] = key
+	}
+	return names
+}()
+
+// ParseKey разбирает описание клавиши вида "Ctrl+R", "Alt+v", "Shift+Tab",
+// "PgDn", "Space" или одиночный символ
+func ParseKey(s string) (keyID, error) {
+	if s == "" {
+		return keyID{}, fmt.Errorf("empty key")
+	}
+
+	// последний символ может быть самой клавишей "+"
+	name, prefix := s, ""
+	if i := strings.LastIndex(s[:len(s)-1], "+"); i >= 0 {
+		name, prefix = s[i+1:], s[:i]
+	}
+
+	var mod tcell.ModMask
+	for _, m := range strings.Split(prefix, "+") {
+		switch strings.ToLower(m) {
+		case "":
+			if prefix != "" {
+				return keyID{}, fmt.Errorf("bad key %q", s)
+			}
+		case "ctrl":
+			mod |= tcell.ModCtrl
+		case "alt", "meta":
+			mod |= tcell.ModAlt
+		case "shift":
+			mod |= tcell.ModShift
+		default:
+			return keyID{}, fmt.Errorf("unknown modifier %q in key %q", m, s)
+		}
+	}
+
+	if utf8.RuneCountInString(name) == 1 || strings.EqualFold(name, "space") {
+		ch, _ := utf8.DecodeRuneInString(name)
+		if len(name) > 1 {
+			ch = ' '
+		}
+
+		switch {
+		case mod&tcell.ModCtrl != 0 && ch >= 'a' && ch <= 'z':
+			return newKeyID(tcell.KeyCtrlA+tcell.Key(ch-'a'), 0, mod), nil
+		case mod&tcell.ModCtrl != 0 && ch >= 'A' && ch <= 'Z':
+			return newKeyID(tcell.KeyCtrlA+tcell.Key(ch-'A'), 0, mod), nil
+		case mod&tcell.ModCtrl != 0:
+			return keyID{}, fmt.Errorf("unsupported key %q", s)
+		case mod&tcell.ModShift != 0:
+			ch = []rune(strings.ToUpper(string(ch)))[0]
+		}
+		return newKeyID(tcell.KeyRune, ch, mod), nil
+	}
+
+	key, ok := keyNames[strings.ToLower(name)]
+	if !ok {
+		return keyID{}, fmt.Errorf("unknown key %q", s)
+	}
+	if key == tcell.KeyTab && mod&tcell.ModShift != 0 {
+		key = tcell.KeyBacktab
+	}
+
+	return newKeyID(key, 0, mod), nil
+}
+
+// Keymap соответствие клавиш действиям
+type Keymap struct {
+	Preset   string
+	bindings Bindings
+	keys     map[keyID]Action
+}
+
+// NewKeymap создаёт раскладку из набора preset и переопределений overrides,
+// клавиши действия из overrides заменяют клавиши набора
+func NewKeymap(preset string, overrides map[string][]string) (*Keymap, error) {
+	if preset == "" {
+		preset = "default"
+	}
+	presetBindings, ok := keyPresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown key preset %q", preset)
+	}
+
+	km := &Keymap{Preset: preset, bindings: make(Bindings), keys: make(map[keyID]Action)}
+	for _, layer := range []Bindings{keyPresets["default"], presetBindings} {
+		for action, keys := range layer {
+			km.bindings[action] = keys
+		}
+	}
+	for action, keys := range overrides {
+		km.bindings[Action(action)] = keys
+	}
+
+	known := make(map[Action]bool, len(actions))
+	for _, info := range actions {
+		known[info.Action] = true
+	}
+
+	for action, keys := range km.bindings {
+		if !known[action] {
+			return nil, fmt.Errorf("unknown action %q", action)
+		}
+		for _, k := range keys {
+			id, err := ParseKey(k)
+			if err != nil {
+				return nil, fmt.Errorf("action %v: %v", action, err)
+			}
+			if other, ok := km.keys[id]; ok && other != action {
+				return nil, fmt.Errorf("key %q is bound to both %v and %v", k, other, action)
+			}
+			km.keys[id] = action
+		}
+	}
+
+	return km, nil
+}
+
+// Action возвращает действие, назначенное клавише события, или пустую строку
+func (km *Keymap) Action(event *tcell.EventKey) Action {
+	return km.keys[newKeyID(event.Key(), event.Rune(), event.Modifiers())]
+}
+
+// Keys возвращает клавиши действия
+func (km *Keymap) Keys(action Action) []string {
+	return km.bindings[action]
+}
+
+// KeysConfig секция [keys] файла настроек
+type KeysConfig struct {
+	Preset   string              `toml:"preset"`
+	Bindings map[string][]string `toml:"bindings"` // действие и его клавиши
+}
+
+// renderHelp возвращает текст экрана помощи с текущими назначениями клавиш,
+// configFile - файл настроек, где они меняются
+func renderHelp(km *Keymap, configFile string) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "[yellow]Клавиши[-] (набор %v)\n\n", km.Preset)
+	for _, info := range actions {
+		keys := km.Keys(info.Action)
+		if len(keys) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "  [green]%-22s[-] %v\n", tview.Escape(strings.Join(keys, ", ")), info.Description)
+	}
+	fmt.Fprintf(&sb, "\nНастройка: секция [keys] файла %v\n", configFile)
+
+	sb.WriteString("\n[yellow]Команды[-]\n\n")
+	for _, c := range commands {
+		fmt.Fprintf(&sb, "  [green]%-22s[-] %v\n", tview.Escape(strings.TrimSpace(":"+c.name+" "+c.args)), c.help)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/2chboarding/boarding/richtext"
+)
+
+// FilterAction что делать с постом или тредом, подходящим под правило
+type FilterAction string
+
+// Действия правил скрытия
+const (
+	FilterNone     FilterAction = ""
+	FilterHide     FilterAction = "hide"     // убрать совсем
+	FilterCollapse FilterAction = "collapse" // оставить свернутым
+)
+
+// FilterRule правило скрытия. Пост подходит под правило, если подходит под все
+// заданные условия, правило без условий не действует. Выражения проверяются
+// без учета регистра по тексту без разметки
+type FilterRule struct {
+	Boards     string       `json:"boards,omitempty"` // доски через запятую, пусто - все
+	Subject    string       `json:"subject,omitempty"`
+	Comment    string       `json:"comment,omitempty"`
+	Name       string       `json:"name,omitempty"`
+	Trip       string       `json:"trip,omitempty"`
+	MinLength  int          `json:"min_length,omitempty"` // текст поста короче MinLength символов
+	Attachment string       `json:"attachment,omitempty"` // расширения файлов через запятую
+	Action     FilterAction `json:"action"`
+
+	// выражения, nil - условие не задано
+	subject, comment, name, trip *regexp.Regexp
+	// ошибка разбора правила, такое правило не действует
+	err error
+}
+
+// Compile проверяет правило и разбирает его выражения
+func (r *FilterRule) Compile() error {
+	r.err = r.compile()
+	return r.err
+}
+
+func (r *FilterRule) compile() error {
+	switch r.Action {
+	case FilterHide, FilterCollapse:
+	case FilterNone:
+		r.Action = FilterHide
+	default:
+		return fmt.Errorf("unknown filter action %q, use hide or collapse", r.Action)
+	}
+
+	if r.Subject == "" && r.Comment == "" && r.Name == "" && r.Trip == "" && r.MinLength <= 0 && r.Attachment == "" {
+		return errors.New("filter rule has no conditions")
+	}
+
+	fields := []struct {
+		name string
+		expr string
+		re   **regexp.Regexp
+	}{
+		{"subject", r.Subject, &r.subject},
+		{"comment", r.Comment, &r.comment},
+		{"name", r.Name, &r.name},
+		{"trip", r.Trip, &r.trip},
+	}
+	for _, f := range fields {
+		*f.re = nil
+		if f.expr == "" {
+			continue
+		}
+
+		re, err := regexp.Compile("(?i)" + f.expr)
+		if err != nil {
+			return fmt.Errorf("%v: %v", f.name, err)
+		}
+		*f.re = re
+	}
+
+	return nil
+}
+
+// Err возвращает ошибку разбора правила
+func (r *FilterRule) Err() error {
+	return r.err
+}
+
+// listContains проверяет, есть ли value в списке через запятую, слеши и
+// точки по краям элементов не учитываются
+func listContains(list, value string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.Trim(strings.TrimSpace(item), "/."), value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match проверяет, подходит ли пост доски boardID под правило
+func (r *FilterRule) Match(boardID string, post PostStruct) bool {
+	if r.err != nil {
+		return false
+	}
+	if r.Boards != "" && !listContains(r.Boards, boardID) {
+		return false
+	}
+
+	if r.Attachment != "" {
+		found := false
+		for _, f := range post.Files {
+			if listContains(r.Attachment, strings.TrimPrefix(path.Ext(f), ".")) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if r.subject == nil && r.name == nil && r.trip == nil && r.comment == nil && r.MinLength <= 0 {
+		return true
+	}
+
+	text := post.plainText()
+	matches := func(re *regexp.Regexp, text string) bool {
+		return re == nil || re.MatchString(text)
+	}
+	if !matches(r.subject, text.subject) || !matches(r.name, text.name) || !matches(r.trip, text.trip) ||
+		!matches(r.comment, text.comment) {
+		return false
+	}
+	if r.MinLength > 0 && utf8.RuneCountInString(strings.TrimSpace(text.comment)) >= r.MinLength {
+		return false
+	}
+
+	return true
+}
+
+// postText поля поста без разметки
+type postText struct {
+	parsed                       bool
+	subject, name, trip, comment string
+}
+
+// plainText возвращает поля поста без разметки. HTML разбирается при первой
+// проверке правил, результат сохраняется для следующих отрисовок
+func (post PostStruct) plainText() *postText {
+	text := post.plain
+	if text == nil {
+		text = &postText{}
+	}
+
+	if !text.parsed {
+		text.subject = richtext.Parse(post.Subject).PlainText()
+		text.name = richtext.Parse(post.Name).PlainText()
+		text.trip = richtext.Parse(post.Trip).PlainText()
+		text.comment = richtext.Parse(post.Comment).PlainText()
+		text.parsed = true
+	}
+
+	return text
+}
+
+// String возвращает описание правила для списка правил
+func (r *FilterRule) String() string {
+	action := "скрыть"
+	if r.Action == FilterCollapse {
+		action = "свернуть"
+	}
+
+	var conds []string
+	add := func(name, value string) {
+		if value != "" {
+			conds = append(conds, name+" "+value)
+		}
+	}
+	add("доски", r.Boards)
+	add("тема", r.Subject)
+	add("текст", r.Comment)
+	add("имя", r.Name)
+	add("трипкод", r.Trip)
+	if r.MinLength > 0 {
+		add("короче", fmt.Sprint(r.MinLength))
+	}
+	add("файлы", r.Attachment)
+
+	result := action + ": " + strings.Join(conds, ", ")
+	if r.err != nil {
+		result += " (ошибка: " + r.err.Error() + ")"
+	}
+	return result
+}
+
+// Killfile правила скрытия тредов и постов, сохраняются между запусками
+type Killfile struct {
+	Rules []FilterRule `json:"rules"`
+}
+
+// killfileFile файл правил в каталоге состояния
+const killfileFile = "killfile.json"
+
+// LoadKillfile загружает правила. Правила с ошибками загружаются, но не
+// действуют, возвращается первая ошибка
+func LoadKillfile() (*Killfile, error) {
+	kf := &Killfile{}
+	if err := loadState(killfileFile, kf); err != nil {
+		return &Killfile{}, err
+	}
+
+	var first error
+	for i := range kf.Rules {
+		if err := kf.Rules[i].Compile(); err != nil && first == nil {
+			first = fmt.Errorf("rule %v: %v", i+1, err)
+		}
+	}
+
+	return kf, first
+}
+
+// Save записывает правила
+func (kf *Killfile) Save() error {
+	return saveState(killfileFile, kf)
+}
+
+// Match возвращает действие первого правила, под которое подходит пост
+func (kf *Killfile) Match(boardID string, post PostStruct) FilterAction {
+	for i := range kf.Rules {
+		if kf.Rules[i].Match(boardID, post) {
+			return kf.Rules[i].Action
+		}
+	}
+
+	return FilterNone
+}
+
+// ApplyKillfile отмечает треды доски, ОП-посты которых подходят под правила,
+// как скрытые, остальные треды становятся активными
+func (ib *ImageBoard) ApplyKillfile(boardID string, kf *Killfile) {
+	board := ib.Boards[boardID]
+	for thID, thread := range board.Threads {
+		thread.Status = Active
+		if kf.Match(boardID, board.Posts[thID]) != FilterNone {
+			thread.Status = Hidden
+		}
+		board.Threads[thID] = thread
+	}
+}
+
+// visibleThreads убирает из threads скрытые треды, свернутые правилами треды
+// остаются, возвращает также число убранных
+func visibleThreads(boardID string, board BoardStruct, threads ThreadPosts, kf *Killfile) (ThreadPosts, int) {
+	var visible ThreadPosts
+	hidden := 0
+	for _, thID := range threads {
+		if board.Threads[thID].Status == Hidden && kf.Match(boardID, board.Posts[thID]) != FilterCollapse {
+			hidden++
+			continue
+		}
+		visible = append(visible, thID)
+	}
+
+	return visible, hidden
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterRuleMatch(t *testing.T) {
+	post := PostStruct{
+		Subject: "Тред <em>котов</em>",
+		Name:    "Аноним",
+		Trip:    "!!abc",
+		Comment: "Смотрите, <strong>кот</strong><br>на фото",
+		Files:   []string{"https://2ch.hk/b/src/1/cat.JPG", "dog.webm"},
+	}
+
+	tests := []struct {
+		name  string
+		board string
+		rule  FilterRule
+		match bool
+	}{
+		{"subject", "b", FilterRule{Subject: "КОТОВ"}, true},
+		{"comment without markup", "b", FilterRule{Comment: "кот на фото"}, true},
+		{"name", "b", FilterRule{Name: "^аноним$"}, true},
+		{"trip", "b", FilterRule{Trip: "abc"}, true},
+		{"other subject", "b", FilterRule{Subject: "собак"}, false},
+
+		// условия должны выполняться все
+		{"all conditions", "b", FilterRule{Subject: "кот", Name: "Аноним", Attachment: "jpg"}, true},
+		{"one condition fails", "b", FilterRule{Subject: "кот", Name: "Модератор"}, false},
+		{"board and subject", "b", FilterRule{Boards: "b", Subject: "собак"}, false},
+
+		// текст поста "Смотрите, кот на фото" 21 символ
+		{"shorter than", "b", FilterRule{MinLength: 22}, true},
+		{"same length", "b", FilterRule{MinLength: 21}, false},
+		{"longer", "b", FilterRule{MinLength: 5}, false},
+
+		{"board", "b", FilterRule{Boards: "/b/", Subject: "кот"}, true},
+		{"board list", "b", FilterRule{Boards: "po, /B/ ,vg", Subject: "кот"}, true},
+		{"other board", "po", FilterRule{Boards: "b,vg", Subject: "кот"}, false},
+
+		{"attachment", "b", FilterRule{Attachment: ".jpg"}, true},
+		{"attachment list", "b", FilterRule{Attachment: "png, .webm"}, true},
+		{"other attachment", "b", FilterRule{Attachment: "png,gif"}, false},
+
+		// правило с ошибкой не действует
+		{"invalid expression", "b", FilterRule{Subject: "кот", Comment: "(кот"}, false},
+		{"unknown action", "b", FilterRule{Subject: "кот", Action: "delete"}, false},
+		{"no conditions", "b", FilterRule{Boards: "b"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			rule.Compile()
+			if got := rule.Match(tt.board, post); got != tt.match {
+				t.Errorf("Match() = %v, want %v (error %v)", got, tt.match, rule.Err())
+			}
+		})
+	}
+}
+
+func TestFilterRuleCompile(t *testing.T) {
+	tests := []struct {
+		rule   FilterRule
+		action FilterAction
+		err    string
+	}{
+		{FilterRule{Subject: "кот"}, FilterHide, ""},
+		{FilterRule{Subject: "кот", Action: FilterCollapse}, FilterCollapse, ""},
+		{FilterRule{MinLength: 3}, FilterHide, ""},
+		{FilterRule{Comment: "(кот"}, FilterHide, "comment: error parsing regexp: missing closing ): `(?i)(кот`"},
+		{FilterRule{Subject: "кот", Action: "delete"}, "delete", `unknown filter action "delete", use hide or collapse`},
+		{FilterRule{Boards: "b"}, FilterHide, "filter rule has no conditions"},
+	}
+
+	for _, tt := range tests {
+		rule := tt.rule
+		err := rule.Compile()
+		if tt.err == "" && err != nil {
+			t.Errorf("%+v: unexpected error %v", tt.rule, err)
+		}
+		if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%+v: error = %v, want %q", tt.rule, err, tt.err)
+		}
+		if rule.Err() != err {
+			t.Errorf("%+v: Err() = %v, want %v", tt.rule, rule.Err(), err)
+		}
+		if rule.Action != tt.action {
+			t.Errorf("%+v: action = %q, want %q", tt.rule, rule.Action, tt.action)
+		}
+	}
+}
+
+func TestVisibleThreads(t *testing.T) {
+	kf := &Killfile{Rules: []FilterRule{
+		{Subject: "свернуть", Action: FilterCollapse},
+		{Subject: "скрыть"},
+	}}
+	for i := range kf.Rules {
+		if err := kf.Rules[i].Compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ib := &ImageBoard{Boards: map[string]BoardStruct{
+		"b": {
+			Threads: ThreadsMap{1: {}, 2: {}, 3: {}, 4: {}},
+			Posts: PostsMap{
+				1: {Subject: "обычный"},
+				2: {Subject: "скрыть"},
+				3: {Subject: "свернуть"},
+				4: {Subject: "скрыть и свернуть"},
+			},
+		},
+	}}
+	ib.ApplyKillfile("b", kf)
+
+	board := ib.Boards["b"]
+	for thID, status := range map[PostID]ThreadStatus{1: Active, 2: Hidden, 3: Hidden, 4: Hidden} {
+		if board.Threads[thID].Status != status {
+			t.Errorf("thread %v status = %v, want %v", thID, board.Threads[thID].Status, status)
+		}
+	}
+
+	// действует первое подходящее правило, свернутые треды остаются в списке
+	visible, hidden := visibleThreads("b", board, ThreadPosts{4, 3, 2, 1}, kf)
+	if want := (ThreadPosts{4, 3, 1}); !reflect.DeepEqual(visible, want) || hidden != 1 {
+		t.Errorf("visibleThreads() = %v, %v, want %v, 1", visible, hidden, want)
+	}
+
+	// без правила сворачивания скрытые треды убираются все
+	kf.Rules = kf.Rules[1:]
+	visible, hidden = visibleThreads("b", board, ThreadPosts{4, 3, 2, 1}, kf)
+	if want := (ThreadPosts{1}); !reflect.DeepEqual(visible, want) || hidden != 3 {
+		t.Errorf("visibleThreads() without collapse rule = %v, %v, want %v, 3", visible, hidden, want)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// filterActions действия правил в порядке вывода в форме
+var filterActions = []struct {
+	Action FilterAction
+	Name   string
+}{
+	{FilterHide, "скрыть"},
+	{FilterCollapse, "свернуть"},
+}
+
+// KillfileEditor список правил скрытия и форма редактирования правила
+type KillfileEditor struct {
+	*tview.Pages
+	list *tview.List
+	form *tview.Form
+	kf   *Killfile
+
+	// номер редактируемого правила, -1 - новое правило
+	editing int
+	rule    FilterRule
+
+	setFocus    func(p tview.Primitive)
+	changedFunc func()
+}
+
+// NewKillfileEditor создает редактор правил kf, setFocus переводит фокус
+// между списком и формой
+func NewKillfileEditor(kf *Killfile, setFocus func(p tview.Primitive)) *KillfileEditor {
+	e := &KillfileEditor{
+		Pages:    tview.NewPages(),
+		list:     tview.NewList(),
+		form:     tview.NewForm(),
+		kf:       kf,
+		setFocus: setFocus,
+	}
+
+	e.list.ShowSecondaryText(false)
+	e.list.SetBorder(true).SetTitle(" Фильтры: a - добавить, Enter - изменить, Delete - удалить ")
+	e.list.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		if index < len(e.kf.Rules) {
+			e.Edit(index)
+		}
+	})
+	e.list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyRune && event.Rune() == 'a', event.Key() == tcell.KeyInsert:
+			e.Edit(-1)
+		case event.Key() == tcell.KeyDelete:
+			e.Delete(e.list.GetCurrentItem())
+		default:
+			return event
+		}
+		return nil
+	})
+
+	e.form.SetBorder(true)
+	e.form.SetCancelFunc(func() { e.Back() })
+
+	e.AddPage("list", e.list, true, true).
+		AddPage("form", e.form, true, false)
+	e.Refresh()
+
+	return e
+}
+
+// SetChangedFunc задает обработчик изменения правил
+func (e *KillfileEditor) SetChangedFunc(handler func()) *KillfileEditor {
+	e.changedFunc = handler
+	return e
+}
+
+// Refresh заполняет список правил
+func (e *KillfileEditor) Refresh() {
+	current := e.list.GetCurrentItem()
+
+	e.list.Clear()
+	for i := range e.kf.Rules {
+		e.list.AddItem(tview.Escape(e.kf.Rules[i].String()), "", 0, nil)
+	}
+	if len(e.kf.Rules) == 0 {
+		e.list.AddItem("Правил нет, a - добавить", "", 0, nil)
+	}
+
+	if current < e.list.GetItemCount() {
+		e.list.SetCurrentItem(current)
+	}
+}
+
+// Edit открывает форму правила i, -1 - нового правила
+func (e *KillfileEditor) Edit(i int) {
+	e.editing = i
+	e.rule = FilterRule{Action: FilterHide}
+	title := " Новое правило "
+	if i >= 0 {
+		e.rule = e.kf.Rules[i]
+		title = " Правило "
+	}
+
+	action := 0
+	for n, a := range filterActions {
+		if a.Action == e.rule.Action {
+			action = n
+		}
+	}
+	minLength := ""
+	if e.rule.MinLength > 0 {
+		minLength = strconv.Itoa(e.rule.MinLength)
+	}
+
+	r := &e.rule
+	e.form.Clear(true).SetTitle(title)
+	e.form.AddInputField("Доски", r.Boards, 0, nil, func(text string) { r.Boards = text }).
+		AddInputField("Тема", r.Subject, 0, nil, func(text string) { r.Subject = text }).
+		AddInputField("Текст", r.Comment, 0, nil, func(text string) { r.Comment = text }).
+		AddInputField("Имя", r.Name, 0, nil, func(text string) { r.Name = text }).
+		AddInputField("Трипкод", r.Trip, 0, nil, func(text string) { r.Trip = text }).
+		AddInputField("Текст короче", minLength, 6, tview.InputFieldInteger, func(text string) {
+			r.MinLength, _ = strconv.Atoi(text)
+		}).
+		AddInputField("Файлы", r.Attachment, 0, nil, func(text string) { r.Attachment = text })
+
+	names := make([]string, len(filterActions))
+	for n, a := range filterActions {
+		names[n] = a.Name
+	}
+	e.form.AddDropDown("Действие", names, action, func(option string, index int) {
+		if index >= 0 {
+			r.Action = filterActions[index].Action
+		}
+	})
+
+	e.form.AddButton("Сохранить", e.save).
+		AddButton("Отмена", func() { e.Back() })
+
+	e.SwitchToPage("form")
+	e.setFocus(e.form)
+}
+
+// save проверяет и сохраняет редактируемое правило, при ошибке форма
+// остается открытой
+func (e *KillfileEditor) save() {
+	if err := e.rule.Compile(); err != nil {
+		Warnf("filter rule: %v", err)
+		return
+	}
+
+	index := e.editing
+	if index >= 0 && index < len(e.kf.Rules) {
+		e.kf.Rules[index] = e.rule
+	} else {
+		e.kf.Rules = append(e.kf.Rules, e.rule)
+		index = len(e.kf.Rules) - 1
+	}
+
+	e.Back()
+	e.list.SetCurrentItem(index)
+	e.changed()
+}
+
+// Delete удаляет правило i
+func (e *KillfileEditor) Delete(i int) {
+	if i < 0 || i >= len(e.kf.Rules) {
+		return
+	}
+
+	e.kf.Rules = append(e.kf.Rules[:i], e.kf.Rules[i+1:]...)
+	e.Refresh()
+	e.changed()
+}
+
+// Back закрывает форму правила без сохранения, возвращает false, если форма
+// не была открыта
+func (e *KillfileEditor) Back() bool {
+	if name, _ := e.GetFrontPage(); name != "form" {
+		return false
+	}
+
+	e.SwitchToPage("list")
+	e.Refresh()
+	e.setFocus(e.list)
+	return true
+}
+
+func (e *KillfileEditor) changed() {
+	if e.changedFunc != nil {
+		e.changedFunc()
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel уровень важности записи журнала
+type LogLevel int
+
+// Уровни журнала
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelOff
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func (l LogLevel) String() string {
+	if l < LevelDebug || l > LevelOff {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+// ParseLogLevel возвращает уровень журнала по названию
+func ParseLogLevel(name string) (LogLevel, error) {
+	for i, n := range logLevelNames {
+		if strings.EqualFold(name, n) {
+			return LogLevel(i), nil
+		}
+	}
+
+	return LevelOff, fmt.Errorf("unknown log level %q, expected one of %v", name, strings.Join(logLevelNames, ", "))
+}
+
+// LogEntry запись журнала
+type LogEntry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+}
+
+// RequestEntry сведения о выполненном HTTP запросе
+type RequestEntry struct {
+	Time     time.Time
+	Method   string // GET или POST
+	URL      string
+	Status   int
+	Size     int
+	Duration time.Duration
+	Err      error
+}
+
+// количество последних записей, хранимых для отладочной панели
+const recentLogSize = 100
+
+// Logger пишет журнал в файл и хранит последние записи и запросы в памяти
+type Logger struct {
+	mu       sync.Mutex
+	level    LogLevel
+	out      *log.Logger
+	entries  []LogEntry
+	requests []RequestEntry
+
+	// вызывается после добавления записи
+	changedFunc func()
+}
+
+// журнал приложения, по умолчанию в файл ничего не пишется
+var logger = &Logger{level: LevelOff}
+
+// defaultLogFile возвращает путь к файлу журнала в каталоге состояния пользователя
+func defaultLogFile() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "boarding.log"), nil
+}
+
+// stateDir возвращает каталог состояния программы $XDG_STATE_HOME/boarding
+func stateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+
+	return filepath.Join(dir, "boarding"), nil
+}
+
+// Open включает запись журнала с уровнем не ниже level в файл filename,
+// пустое имя означает файл по умолчанию
+func (lg *Logger) Open(level LogLevel, filename string) (io.Closer, error) {
+	if filename == "" {
+		var err error
+		if filename, err = defaultLogFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+
+	fl, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	lg.mu.Lock()
+	lg.level = level
+	lg.out = log.New(fl, "", log.LstdFlags|log.Lmicroseconds)
+	lg.mu.Unlock()
+
+	return fl, nil
+}
+
+// SetChangedFunc устанавливает обработчик, вызываемый при новых записях
+func (lg *Logger) SetChangedFunc(handler func()) {
+	lg.mu.Lock()
+	lg.changedFunc = handler
+	lg.mu.Unlock()
+}
+
+func (lg *Logger) notify() {
+	lg.mu.Lock()
+	handler := lg.changedFunc
+	lg.mu.Unlock()
+
+	if handler != nil {
+		handler()
+	}
+}
+
+// Logf добавляет запись в журнал. В памяти хранятся записи от уровня info,
+// отладочные - только если включен отладочный журнал
+func (lg *Logger) Logf(level LogLevel, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+
+	lg.mu.Lock()
+	if level >= lg.level && lg.out != nil {
+		lg.out.Printf("[%v] %v", level, msg)
+	}
+
+	keep := level >= LevelInfo || level >= lg.level
+	if keep {
+		lg.entries = append(lg.entries, LogEntry{time.Now(), level, msg})
+		if len(lg.entries) > recentLogSize {
+			lg.entries = lg.entries[len(lg.entries)-recentLogSize:]
+		}
+	}
+	lg.mu.Unlock()
+
+	if keep {
+		lg.notify()
+	}
+}
+
+// Request записывает сведения о HTTP запросе
+func (lg *Logger) Request(req RequestEntry) {
+	lg.mu.Lock()
+	lg.requests = append(lg.requests, req)
+	if len(lg.requests) > recentLogSize {
+		lg.requests = lg.requests[len(lg.requests)-recentLogSize:]
+	}
+	lg.mu.Unlock()
+
+	if req.Err != nil {
+		lg.Logf(LevelError, "%v %v failed after %v: %v", req.Method, req.URL, req.Duration, req.Err)
+	} else {
+		lg.Logf(LevelDebug, "%v %v: %v, %v bytes in %v", req.Method, req.URL, req.Status, req.Size, req.Duration)
+	}
+}
+
+// Recent возвращает копии последних записей и запросов
+func (lg *Logger) Recent() ([]LogEntry, []RequestEntry) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	return append([]LogEntry(nil), lg.entries...), append([]RequestEntry(nil), lg.requests...)
+}
+
+// LastRequest возвращает последний запрос
+func (lg *Logger) LastRequest() (RequestEntry, bool) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	if len(lg.requests) == 0 {
+		return RequestEntry{}, false
+	}
+	return lg.requests[len(lg.requests)-1], true
+}
+
+// LastEntry возвращает последнюю запись уровня не ниже level
+func (lg *Logger) LastEntry(level LogLevel) (LogEntry, bool) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+
+	for i := len(lg.entries) - 1; i >= 0; i-- {
+		if lg.entries[i].Level >= level {
+			return lg.entries[i], true
+		}
+	}
+	return LogEntry{}, false
+}
+
+// Debugf пишет отладочное сообщение
+func Debugf(format string, args ...interface{}) { logger.Logf(LevelDebug, format, args...) }
+
+// Infof пишет информационное сообщение
+func Infof(format string, args ...interface{}) { logger.Logf(LevelInfo, format, args...) }
+
+// Warnf пишет предупреждение
+func Warnf(format string, args ...interface{}) { logger.Logf(LevelWarn, format, args...) }
+
+// Errorf пишет сообщение об ошибке
+func Errorf(format string, args ...interface{}) { logger.Logf(LevelError, format, args...) }
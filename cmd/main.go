@@ -1,135 +1,1579 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
 
 	"github.com/gdamore/tcell"
 
 	"github.com/rivo/tview"
 )
 
+var (
+	configFlag        = flag.String("config", defaultConfigFile(), "файл настроек")
+	baseURLFlag       = flag.String("base-url", "", "адрес сайта, по умолчанию https://2ch.hk")
+	fetcherFlag       = flag.String("fetcher", "", "загрузка данных: http или stub (JSON из каталога stub_dir)")
+	logLevelFlag      = flag.String("log", "", "уровень журнала: debug, info, warn, error; также переменная BOARDING_LOG")
+	logFileFlag       = flag.String("log-file", "", "файл журнала, по умолчанию $XDG_STATE_HOME/boarding/boarding.log")
+	keysFlag          = flag.String("keys", "", "набор клавиш: default, vi, emacs")
+	watchIntervalFlag = flag.Duration("watch-interval", 0, "период обновления отслеживаемых тредов")
+	themeFlag         = flag.String("theme", "", "тема: dark, light, solarized, 2ch-classic")
+	colorModeFlag     = flag.String("color-mode", "", "режим цвета: auto, truecolor, 256, mono")
+	boardFlag         = flag.String("board", "", "доска, список тредов которой открывается при запуске")
+)
+
 func main() {
-	Display()
+	flag.Usage = usage
+	flag.Parse()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration %v:\n  %v\n", *configFlag,
+			strings.ReplaceAll(err.Error(), "\n", "\n  "))
+		os.Exit(2)
+	}
+
+	if flag.NArg() > 0 {
+		runCommand(cfg, flag.Args())
+		return
+	}
+
+	if closer := initLog(cfg); closer != nil {
+		defer closer.Close()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			Errorf("panic: %v\n%s", p, debug.Stack())
+			panic(p)
+		}
+	}()
+
+	SetupFetcher(cfg)
+	Display(cfg)
 }
 
-func loadBoardsList(lst *tview.TreeView, ib *ImageBoard) {
-	ib.FetchCategories()
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Использование: %v [флаги] [config dump]\n\n", os.Args[0])
+	fmt.Fprintf(out, "  config dump - вывести действующие настройки\n\nФлаги:\n")
+	flag.PrintDefaults()
+}
 
-	root := tview.NewTreeNode("Доски")
-	lst.SetRoot(root).SetCurrentNode(root).SetTopLevel(0)
+// loadConfig читает файл настроек и переопределяет его переменными окружения
+// и флагами
+func loadConfig() (*Config, error) {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			explicit = true
+		}
+	})
+
+	cfg, err := LoadConfig(*configFlag, explicit)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ApplyEnv()
+	cfg.ApplyFlags(flag.CommandLine)
 
-	for _, bcat := range ib.Categories {
-		cat := tview.NewTreeNode(bcat).SetExpanded(false)
-		root.AddChild(cat)
+	return cfg, cfg.Validate()
+}
 
-		for _, b := range ib.BoardsByCategory[bcat] {
-			brd := tview.NewTreeNode(fmt.Sprintf("/%v/", b))
-			brd.SetReference(b)
-			cat.AddChild(brd)
+// runCommand выполняет команду, заданную аргументами
+func runCommand(cfg *Config, args []string) {
+	if len(args) == 2 && args[0] == "config" && args[1] == "dump" {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		return
 	}
 
+	fmt.Fprintf(os.Stderr, "unknown command: %v\n", strings.Join(args, " "))
+	flag.Usage()
+	os.Exit(2)
 }
 
-func loadThreadsList(boardID string, tl *tview.List, ib *ImageBoard) {
-	ib.UpdateBoard(boardID)
+// initLog включает журнал, если задан уровень
+func initLog(cfg *Config) io.Closer {
+	if cfg.Log.Level == "" {
+		return nil
+	}
+
+	// уровень уже проверен при загрузке настроек
+	level, _ := ParseLogLevel(cfg.Log.Level)
+
+	closer, err := logger.Open(level, cfg.Log.File)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't open log: %v\n", err)
+		os.Exit(1)
+	}
+
+	Infof("boarding started, log level %v", level)
+	return closer
+}
+
+// loadBoardsList загружает каталог досок и строит дерево, возвращает узел
+// избранного
+func loadBoardsList(lst *tview.TreeView, ib *ImageBoard, fav *Favorites) *tview.TreeNode {
+	ib.FetchCategories()
+
+	return buildBoardTree(lst, ib, fav, "")
+}
+
+// fillThreadsList заполняет список тредами threads, title возвращает заголовок
+// треда и второстепенный текст
+func fillThreadsList(threads ThreadPosts, tl *tview.List, title func(PostID) (string, string)) {
 	tl.Clear()
 
-	for _, t := range ib.Boards[boardID].ThreadsIndex {
-		tl.AddItem(ib.Boards[boardID].Posts[t].Subject, "", 0, nil)
+	for _, t := range threads {
+		text, secondary := title(t)
+		tl.AddItem(text, secondary, 0, nil)
 	}
 }
 
 // Display function run application and display interface
-func Display() {
+func Display(cfg *Config) {
+	// настройки проверены при загрузке
+	keymap, _ := NewKeymap(cfg.Keys.Preset, cfg.Keys.Bindings)
 
 	// TUI
 	app := tview.NewApplication()
 	bs := tview.NewTreeView()
-	tl := tview.NewList().ShowSecondaryText(false)
+	tl := tview.NewList()
 	//tv := tview.NewTextView().SetWordWrap(true).SetRegions(true).SetDynamicColors(true)
-	tv := &ThreadView{Box: tview.NewBox()}
+	tv := NewThreadView()
+	tabBar := NewTabBar()
 
 	bs.SetBorder(true)
 	tl.SetBorder(true)
 	//tv.SetBorder(true)
 
-	flex := tview.NewFlex().AddItem(bs, 0, 1, true).
-		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
-			AddItem(tl, 0, 2, false).AddItem(tv, 0, 5, false), 0, 8, false)
+	threadFilter := tview.NewInputField().SetPlaceholder("фильтр тредов: текст или /выражение/")
+	content := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(threadFilter, 1, 0, false).
+		AddItem(tl, 0, cfg.Layout.Threads, false).
+		AddItem(tabBar, 1, 0, false).
+		AddItem(tv, 0, cfg.Layout.Thread, false)
+	// над деревом досок строка поиска доски
+	boardFilter := tview.NewInputField().SetPlaceholder("поиск доски")
+	boards := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(boardFilter, 1, 0, false).
+		AddItem(bs, 0, 1, true)
+	flex := tview.NewFlex().AddItem(boards, 0, cfg.Layout.Boards, true).
+		AddItem(content, 0, cfg.Layout.Content, false)
+
+	// внизу строка состояния, на ее месте открывается строка команд
+	statusBar := NewStatusBar()
+	cmdLine := tview.NewInputField().SetLabel(":")
+	bottom := tview.NewPages().
+		AddPage("status", statusBar, true, true).
+		AddPage("command", cmdLine, true, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(flex, 0, 1, true).
+		AddItem(bottom, 1, 0, false)
+
+	debugView := NewDebugView()
+	// записи добавляются и при загрузке в фоне, панель обновляется в горутине
+	// интерфейса, отдельная горутина не блокирует обработчики событий
+	logger.SetChangedFunc(func() { go app.QueueUpdateDraw(debugView.Update) })
+
+	helpView := tview.NewTextView().SetDynamicColors(true).SetText(renderHelp(keymap, *configFlag))
+	helpView.SetBorder(true).SetTitle(" Помощь ")
+	helpVisible := false
+
+	searchList := tview.NewList()
+	searchList.SetBorder(true)
+	searchVisible := false
+
+	// правила скрытия сохраняются сразу при изменении
+	killfile, err := LoadKillfile()
+	if err != nil {
+		Warnf("killfile: %v", err)
+	}
+	killfileEditor := NewKillfileEditor(killfile, func(p tview.Primitive) { app.SetFocus(p) })
+	killfileVisible := false
+
+	// скрытые вручную треды и посты сохраняются сразу при изменении
+	hidden, err := LoadHidden()
+	if err != nil {
+		Warnf("can't load hidden threads and posts: %v", err)
+	}
+	hiddenList := tview.NewList()
+	hiddenList.SetBorder(true)
+	hiddenVisible := false
+
+	// черновик поста сохраняется, пока форма открывается для того же треда
+	compose := NewComposeView()
+	composeVisible := false
+
+	// капча запрашивается перед отправкой поста
+	captcha := captchaProviders[cfg.Posting.Captcha](cfg.Posting)
+	if stubFetcher {
+		// с заглушками посты не отправляются, капча не нужна
+		captcha = noCaptcha{}
+	}
+	captchaView := NewCaptchaView(cfg.Posting.Viewer)
+	captchaVisible := false
+
+	pages := tview.NewPages().
+		AddPage("main", layout, true, true).
+		AddPage("debug", centered(debugView), true, false).
+		AddPage("help", centered(helpView), true, false).
+		AddPage("search", centered(searchList), true, false).
+		AddPage("killfile", centered(killfileEditor), true, false).
+		AddPage("hidden", centered(hiddenList), true, false).
+		AddPage("compose", centered(compose), true, false).
+		AddPage("captcha", centered(captchaView), true, false)
 
-	app.SetRoot(flex, true)
+	app.SetRoot(pages, true).EnableMouse(true)
 	app.SetFocus(bs)
 
-	ib := ImageBoard{}
-	loadBoardsList(bs, &ib)
+	// избранные доски сохраняются сразу при изменении
+	fav, err := LoadFavorites()
+	if err != nil {
+		Warnf("can't load favorites: %v", err)
+	}
+
+	// все загруженные посты индексируются для полнотекстового поиска, индекс
+	// сохраняется при выходе
+	indexFile, err := defaultIndexFile()
+	ib := ImageBoard{Index: NewSearchIndex()}
+	if err == nil {
+		if ib.Index, err = LoadSearchIndex(indexFile); err != nil {
+			Warnf("can't load search index from %v: %v", indexFile, err)
+		}
+		Infof("search index: %v posts", ib.Index.Len())
+	}
+	favNode := loadBoardsList(bs, &ib, fav)
+
+	// тема применяется при первой отрисовке, когда известны возможности терминала
+	themeIndex, _ := findTheme(cfg.Theme)
+	colorMode := ""
+	var colors ThemeColors
+	applyTheme := func() {
+		theme := &themes[themeIndex]
+		colors = theme.Colors(colorMode)
+		if colorMode != ColorModeMono {
+			colors = overrideColors(colors, cfg.Colors)
+		}
 
-	var boardID string
+		setWidgetColors(colors, bs, boardFilter, threadFilter, tl, tabBar, tv, statusBar, cmdLine,
+			helpView, searchList, killfileEditor, hiddenList, compose.Form, captchaView, debugView.TextView)
+		captchaView.SetMono(colorMode == ColorModeMono)
+		tv.SetStyles(colors.ThreadStyles(colorMode == ColorModeMono))
+		Infof("theme %v, color mode %v", theme.Name, colorMode)
+	}
+	// доска списка тредов и доска и номер показанного треда, они различаются,
+	// когда открыта вкладка треда с другой доски
+	var listBoardID, boardID string
+	var threadID PostID
 	widgetFocus := 0
+
+	// настройки отображения открывавшихся тредов
+	states := make(map[string]map[PostID]*ThreadViewState)
+	threadState := func() *ThreadViewState {
+		if states[boardID] == nil {
+			states[boardID] = make(map[PostID]*ThreadViewState)
+		}
+		if states[boardID][threadID] == nil {
+			states[boardID][threadID] = &ThreadViewState{
+				Collapsed: make(map[PostID]bool),
+				Revealed:  make(map[PostID]bool),
+			}
+		}
+		return states[boardID][threadID]
+	}
+	postFilter := func(board string, postID PostID) FilterAction {
+		if hidden.Contains(board, postID, false) {
+			return FilterHide
+		}
+		return killfile.Match(board, ib.Boards[board].Posts[postID])
+	}
+	showThread := func() {
+		tv.SetText(ib.RenderThreadMode(boardID, threadID, threadState(), postFilter))
+	}
+	// заголовок треда в списке и сведения о нем для подробного списка, у
+	// отслеживаемых тредов виден счётчик новых постов. Свернутые фильтрами
+	// треды показываются одним номером, показанные скрытые треды отмечены
+	listMode := cfg.ThreadList
+	tl.ShowSecondaryText(listMode == ListDetailed)
+	showHiddenThreads := false
+	threadTitle := func(thID PostID) (string, string) {
+		board := ib.Boards[listBoardID]
+		title := threadSubject(board, thID)
+
+		if board.Threads[thID].Status == Hidden {
+			if !showHiddenThreads {
+				return fmt.Sprintf("[скрыт фильтром] №%v", thID), ""
+			}
+			title = "⊘ " + title
+		}
+
+		if state := states[listBoardID][thID]; state != nil && state.Watched {
+			title = "★ " + title
+			if n := state.Unread(board.Threads[thID].Posts); n > 0 {
+				title += fmt.Sprintf(" (+%v)", n)
+			}
+		}
+
+		if listMode == ListCompact {
+			return tview.Escape(fmt.Sprintf("%v (%v)", title, board.Threads[thID].PostsCount)), ""
+		}
+		return tview.Escape(title), tview.Escape(threadDetails(board, thID, time.Now()))
+	}
+	// треды списка, прошедшие фильтр, и число скрытых правилами
+	var listThreads ThreadPosts
+	var listFilter *regexp.Regexp
+	listHidden := 0
+	updateThreadTitles := func() {
+		for i, thID := range listThreads {
+			text, secondary := threadTitle(thID)
+			tl.SetItemText(i, text, secondary)
+		}
+	}
 	widgets := []tview.Primitive{bs, tl, tv}
 
+	// порядок тредов в списке переключается клавишей
+	orderIndex, _ := findThreadOrder(cfg.ThreadOrder)
+
+	// showThreadsList показывает треды доски, прошедшие фильтр, треды
+	// запоминаются до заполнения списка, которое вызывает обработчики выбора
+	showThreadsList := func() {
+		board := ib.Boards[listBoardID]
+		listThreads, listHidden = filterThreads(board, listFilter), 0
+		if !showHiddenThreads {
+			listThreads, listHidden = visibleThreads(listBoardID, board, listThreads, killfile)
+		}
+		fillThreadsList(listThreads, tl, threadTitle)
+	}
+	// reshowThreadsList заново заполняет список, выбранный тред остается выбранным
+	reshowThreadsList := func() {
+		var selected PostID
+		if current := tl.GetCurrentItem(); current < len(listThreads) {
+			selected = listThreads[current]
+		}
+
+		showThreadsList()
+		for i, thID := range listThreads {
+			if thID == selected {
+				tl.SetCurrentItem(i)
+			}
+		}
+	}
+	// applyHiding отмечает треды списка, скрытые правилами и вручную
+	applyHiding := func() {
+		ib.ApplyKillfile(listBoardID, killfile)
+		ib.ApplyHidden(listBoardID, hidden)
+	}
+	// loadThreads загружает треды доски списка
+	loadThreads := func() error {
+		if err := ib.UpdateBoard(listBoardID); err != nil {
+			return err
+		}
+		applyHiding()
+		ib.SortThreads(listBoardID, threadOrders[orderIndex].Order)
+		showThreadsList()
+		return nil
+	}
+
+	// переход между панелями
+	focusPanel := func(i int) {
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(widgets) {
+			i = len(widgets) - 1
+		}
+		widgetFocus = i
+		app.SetFocus(widgets[widgetFocus])
+	}
+
+	// вкладки открытых тредов сохраняются между запусками
+	tabs, err := LoadTabs()
+	if err != nil {
+		Warnf("can't load tabs: %v", err)
+	}
+	updateTabBar := func() {
+		titles := make([]string, len(tabs.Tabs))
+		for i, tab := range tabs.Tabs {
+			titles[i] = tab.Title
+		}
+		tabBar.SetTabs(titles, tabs.Current)
+	}
+	// saveTabScroll запоминает положение в треде текущей вкладки
+	saveTabScroll := func() {
+		if tab := tabs.CurrentTab(); tab != nil && tab.Board == boardID && tab.Thread == threadID {
+			tab.Scroll = tv.Scroll()
+		}
+	}
+	// showTab показывает тред вкладки i, тред загружается, если его еще нет
+	var closeTab func()
+	showTab := func(i int) {
+		if i < 0 || i >= len(tabs.Tabs) {
+			return
+		}
+
+		saveTabScroll()
+		tabs.Current = i
+		tab := tabs.Tabs[i]
+		boardID, threadID = tab.Board, tab.Thread
+
+		board, ok := ib.Boards[boardID]
+		if !ok {
+			Warnf("board of tab /%v/%v doesn't exist", tab.Board, tab.Thread)
+			closeTab()
+			return
+		}
+		if _, ok := board.Threads[threadID]; !ok {
+			// вкладка остается, тред загрузится при обновлении
+			if err := ib.UpdateThread(boardID, threadID); err != nil {
+				Warnf("%v", err)
+			} else {
+				threadState().MarkRead(board.Threads[threadID].Posts)
+			}
+		}
+
+		showThread()
+		tv.SetScroll(tab.Scroll)
+		updateTabBar()
+		focusPanel(2)
+	}
+	closeTab = func() {
+		if tabs.CurrentTab() == nil {
+			return
+		}
+
+		tabs.Close(tabs.Current)
+		if tabs.Current < 0 {
+			threadID = 0
+			tv.SetText("")
+			updateTabBar()
+			return
+		}
+		showTab(tabs.Current)
+	}
+	tabBar.SetSelectedFunc(showTab)
+
+	// openThread загружает тред и открывает его во вкладке
+	openThread := func(board string, thID PostID) error {
+		if _, ok := ib.Boards[board]; !ok {
+			return fmt.Errorf("unknown board %q", board)
+		}
+
+		saveTabScroll()
+		if err := ib.UpdateThread(board, thID); err != nil {
+			return err
+		}
+
+		boardID, threadID = board, thID
+		threadState().MarkRead(ib.Boards[boardID].Threads[threadID].Posts)
+
+		title := fmt.Sprintf("/%v/ %v", boardID, threadSubject(ib.Boards[boardID], threadID))
+		showTab(tabs.Open(Tab{Board: boardID, Thread: threadID, Title: title}))
+		return nil
+	}
+
+	// openBoard загружает список тредов доски
+	openBoard := func(board string) error {
+		if _, ok := ib.Boards[board]; !ok {
+			return fmt.Errorf("unknown board %q", board)
+		}
+
+		// при ошибке остается прежний список
+		prev := listBoardID
+		listBoardID = board
+		if err := loadThreads(); err != nil {
+			listBoardID = prev
+			return err
+		}
+		focusPanel(1)
+		return nil
+	}
+
+	// gotoPost переходит к посту открытого треда, а если его там нет, к посту
+	// другого загруженного треда доски
+	gotoPost := func(postID PostID) error {
+		board := boardID
+		if threadID == 0 {
+			board = listBoardID
+		}
+
+		if threadID != 0 {
+			for _, p := range ib.Boards[boardID].Threads[threadID].Posts {
+				if p == postID {
+					if !tv.ScrollToAnchor(fmt.Sprint(postID)) {
+						return fmt.Errorf("post %v is hidden in collapsed replies or by filter", postID)
+					}
+					focusPanel(2)
+					return nil
+				}
+			}
+		}
+
+		for thID, thread := range ib.Boards[board].Threads {
+			for _, p := range thread.Posts {
+				if p == postID {
+					if err := openThread(board, thID); err != nil {
+						return err
+					}
+					tv.ScrollToAnchor(fmt.Sprint(postID))
+					return nil
+				}
+			}
+		}
+
+		return fmt.Errorf("post %v not found on /%v/", postID, board)
+	}
+
+	// панель может получить фокус щелчком мыши
+	bs.SetFocusFunc(func() { widgetFocus = 0 })
+	tl.SetFocusFunc(func() { widgetFocus = 1 })
+	tv.SetFocusFunc(func() { widgetFocus = 2 })
+
 	bs.SetSelectedFunc(func(node *tview.TreeNode) {
 		if node.GetReference() != nil {
-			boardID = node.GetReference().(string)
-			loadThreadsList(boardID, tl, &ib)
-			app.SetFocus(tl)
-			widgetFocus = 1
+			if err := openBoard(node.GetReference().(string)); err != nil {
+				Warnf("%v", err)
+			}
 		} else {
 			node.SetExpanded(!node.IsExpanded())
 		}
 
 	})
 
+	// пост незагруженного треда показывается после загрузки треда в фоне, до
+	// этого в превью заглушка, ошибка загрузки показывается в превью один раз
+	type previewThread struct {
+		board  string
+		thread PostID
+	}
+	loadingPreviews := make(map[previewThread]bool)
+	previewErrors := make(map[previewThread]error)
+	tv.SetPreviewFunc(func(link Link) (string, bool) {
+		board, thID, missing := ib.MissingLinkedThread(boardID, link)
+		if !missing {
+			return ib.RenderLinkedPost(boardID, link)
+		}
+
+		th := previewThread{board, thID}
+		if err, ok := previewErrors[th]; ok {
+			delete(previewErrors, th)
+			return RenderPreviewMessage(PostID(link.Post), err.Error()), true
+		}
+
+		if !loadingPreviews[th] {
+			loadingPreviews[th] = true
+			go func() {
+				data, err := GetThread(th.board, th.thread)
+				app.QueueUpdateDraw(func() {
+					delete(loadingPreviews, th)
+					if err == nil {
+						err = ib.ApplyThread(th.board, th.thread, data)
+					}
+					if err != nil {
+						Warnf("%v", err)
+						previewErrors[th] = err
+					}
+					tv.UpdatePreviews()
+				})
+			}()
+		}
+		return RenderPreviewMessage(PostID(link.Post), "Загрузка…"), true
+	})
+
+	tv.SetLinkFunc(func(link Link) {
+		switch link.URL {
+		case collapseLinkURL:
+			state := threadState()
+			state.Collapsed[PostID(link.Post)] = !state.Collapsed[PostID(link.Post)]
+			showThread()
+		case revealLinkURL:
+			threadState().Revealed[PostID(link.Post)] = true
+			showThread()
+			tv.ScrollToAnchor(fmt.Sprint(link.Post))
+		}
+	})
+
 	tl.SetSelectedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
-		if boardID != "" {
-			thID := ib.Boards[boardID].ThreadsIndex[index]
+		if listBoardID != "" && index < len(listThreads) {
+			thID := listThreads[index]
 			/*post := ib.Boards[boardID].Posts[thID]
 			tv.SetPost(&post)*/
-			ib.UpdateThread(boardID, thID)
-			t := ib.RenderThread(boardID, thID)
-			_ = t
-			tv.SetText(t)
-			tv.ScrollToBeginning()
-			app.SetFocus(tv)
-			widgetFocus = 2
-
+			if err := openThread(listBoardID, thID); err != nil {
+				Warnf("%v", err)
+				return
+			}
+			text, secondary := threadTitle(threadID)
+			tl.SetItemText(index, text, secondary)
 		}
 	})
 
+	// при листании списка тред показывается без вкладки
 	tl.SetChangedFunc(func(index int, mainText string, secondaryText string, shortcut rune) {
-		if boardID != "" {
-			thID := ib.Boards[boardID].ThreadsIndex[index]
-			t := ib.RenderThread(boardID, thID)
-			_ = t
-			tv.SetText(t)
+		if listBoardID != "" && index < len(listThreads) {
+			saveTabScroll()
+			tabs.Current = -1
+			updateTabBar()
+
+			boardID = listBoardID
+			threadID = listThreads[index]
+			showThread()
 			tv.ScrollToBeginning()
 		}
 	})
 
 	//panic(nil)
 
-	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyLeft:
-			widgetFocus--
-			if widgetFocus < 0 {
-				widgetFocus = 0
+	// обновление открытого треда или списка тредов
+	refresh := func() {
+		if widgetFocus == 2 && threadID != 0 {
+			if err := ib.UpdateThread(boardID, threadID); err != nil {
+				Warnf("%v", err)
+				return
 			}
-			app.SetFocus(widgets[widgetFocus])
-		case tcell.KeyRight:
-			widgetFocus++
-			if widgetFocus >= len(widgets) {
-				widgetFocus = len(widgets) - 1
+			showThread()
+			return
+		}
+
+		if listBoardID == "" {
+			return
+		}
+
+		current := tl.GetCurrentItem()
+		if err := loadThreads(); err != nil {
+			Warnf("%v", err)
+			return
+		}
+		if current < tl.GetItemCount() {
+			tl.SetCurrentItem(current)
+		}
+	}
+
+	// отслеживаемые треды загружаются в фоне, состояние тредов и данные
+	// доски меняются только в горутине интерфейса
+	type watchedThread struct {
+		board  string
+		thread PostID
+	}
+	watchedThreads := func() []watchedThread {
+		var watched []watchedThread
+		for board, threads := range states {
+			for thID, state := range threads {
+				if state.Watched {
+					watched = append(watched, watchedThread{board, thID})
+				}
+			}
+		}
+		return watched
+	}
+	refreshWatched := func() {
+		watchedCh := make(chan []watchedThread)
+		app.QueueUpdate(func() { watchedCh <- watchedThreads() })
+
+		for _, w := range <-watchedCh {
+			Debugf("refreshing watched thread /%v/%v", w.board, w.thread)
+			data, err := GetThread(w.board, w.thread)
+			if err != nil {
+				Warnf("%v", err)
+				continue
+			}
+
+			w := w
+			app.QueueUpdate(func() {
+				if err := ib.ApplyThread(w.board, w.thread, data); err != nil {
+					Warnf("%v", err)
+				}
+			})
+		}
+
+		app.QueueUpdateDraw(func() {
+			updateThreadTitles()
+			if threadID != 0 && threadState().Watched {
+				showThread()
+			}
+		})
+	}
+	go func() {
+		for range time.Tick(cfg.WatchInterval.Duration) {
+			refreshWatched()
+		}
+	}()
+
+	toggleWatch := func() {
+		if boardID == "" || threadID == 0 {
+			return
+		}
+
+		state := threadState()
+		state.Watched = !state.Watched
+		updateThreadTitles()
+	}
+
+	// строка состояния обновляется перед каждой отрисовкой
+	updateStatus := func() {
+		var left string
+		switch {
+		case threadID != 0:
+			thread := ib.Boards[boardID].Threads[threadID]
+			left = fmt.Sprintf("/%v/%v · %v", boardID, threadID, plural(len(thread.Posts), "пост", "поста", "постов"))
+			if !thread.Updated.IsZero() {
+				left += " · обновлен " + thread.Updated.Format("15:04:05")
+			}
+			if threadState().Watched {
+				left += " · ★"
+			}
+		case listBoardID != "":
+			board := ib.Boards[listBoardID]
+			left = fmt.Sprintf("/%v/ %v · %v, %v", listBoardID, board.Name,
+				plural(len(board.ThreadsIndex), "тред", "треда", "тредов"), threadOrders[orderIndex].Name)
+			if listFilter != nil {
+				left += fmt.Sprintf(" · по фильтру %v", len(listThreads))
+			}
+			if listHidden > 0 {
+				left += fmt.Sprintf(" · скрыто %v", listHidden)
+			}
+			if !board.Updated.IsZero() {
+				left += " · обновлен " + board.Updated.Format("15:04:05")
+			}
+		default:
+			left = "Выберите доску"
+		}
+
+		right := ""
+		if req, ok := logger.LastRequest(); ok {
+			right = requestStatus(req)
+		}
+		statusBar.SetText(left, right)
+
+		if entry, ok := logger.LastEntry(LevelWarn); ok {
+			statusBar.SetMessage(entry)
+		}
+	}
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		if colorMode == "" {
+			colorMode = detectColorMode(cfg.ColorMode, screen)
+			applyTheme()
+		}
+		updateStatus()
+		return false
+	})
+
+	togglePage := func(name string, visible bool) {
+		if visible {
+			pages.ShowPage(name)
+		} else {
+			pages.HidePage(name)
+		}
+		app.SetFocus(widgets[widgetFocus])
+	}
+
+	// результаты поиска открываются поверх панелей, Enter переходит к посту
+	showSearch := func(visible bool) {
+		searchVisible = visible
+		togglePage("search", visible)
+		if visible {
+			app.SetFocus(searchList)
+		}
+	}
+	showSearchResults := func(title string, hits []SearchHit) {
+		searchList.Clear()
+		searchList.SetTitle(fmt.Sprintf(" Поиск: %v (%v) ", tview.Escape(title), len(hits)))
+		// тред находки из индекса мог уже удалиться
+		openHit := func(hit SearchHit) error {
+			if err := openThread(hit.Board, hit.Thread); err != nil {
+				return err
+			}
+			tv.ScrollToAnchor(fmt.Sprint(hit.Post))
+			return nil
+		}
+		for _, hit := range hits {
+			hit := hit
+			searchList.AddItem(hit.Snippet, fmt.Sprintf("/%v/%v #%v", hit.Board, hit.Thread, hit.Post), 0, func() {
+				showSearch(false)
+				if err := openHit(hit); err != nil {
+					Warnf("/%v/%v: %v", hit.Board, hit.Thread, err)
+				}
+			})
+		}
+		showSearch(true)
+	}
+
+	// executeCommand выполняет команду из строки команд
+	executeCommand := func(line string) error {
+		cmd, err := ParseCommand(line)
+		if err != nil {
+			return err
+		}
+
+		switch cmd.Name {
+		case "open":
+			board, thID, err := ParseThreadRef(cmd.Args)
+			if err != nil {
+				return err
+			}
+			if thID == 0 {
+				return openBoard(board)
+			}
+			return openThread(board, thID)
+		case "goto":
+			if len(cmd.Args) != 1 {
+				return errors.New("usage: goto POST")
+			}
+			postID, err := ParsePostID(cmd.Args[0])
+			if err != nil {
+				return err
+			}
+			return gotoPost(postID)
+		case "refresh":
+			refresh()
+		case "watch":
+			if threadID == 0 {
+				return errors.New("no thread is open")
+			}
+			toggleWatch()
+		case "export":
+			if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+				return errors.New("usage: export md [FILE]")
+			}
+			if threadID == 0 {
+				return errors.New("no thread is open")
+			}
+			filename := exportFilename(boardID, threadID, cmd.Args[0])
+			if len(cmd.Args) == 2 {
+				filename = cmd.Args[1]
+			}
+			if err := ib.ExportThread(filename, cmd.Args[0], boardID, threadID); err != nil {
+				return err
 			}
+			Infof("thread /%v/%v exported to %v", boardID, threadID, filename)
+			statusBar.SetMessage(LogEntry{Time: time.Now(), Level: LevelInfo, Message: "Тред сохранен в " + filename})
+		case "search":
+			if len(cmd.Args) == 0 {
+				return errors.New("usage: search TEXT|/REGEXP/")
+			}
+			board := boardID
+			if board == "" {
+				board = listBoardID
+			}
+			if board == "" {
+				return errors.New("no board is open")
+			}
+			query := strings.Join(cmd.Args, " ")
+			re, err := parseFilter(query)
+			if err != nil {
+				return err
+			}
+			hits := ib.SearchPosts(board, re)
+			if len(hits) == 0 {
+				return fmt.Errorf("%q not found in loaded posts of /%v/", query, board)
+			}
+			showSearchResults(fmt.Sprintf("/%v/ %v", board, query), hits)
+		case "find":
+			if len(cmd.Args) == 0 {
+				return errors.New("usage: find WORDS")
+			}
+			query := strings.Join(cmd.Args, " ")
+			hits, err := ib.Index.Search(query)
+			if err != nil {
+				return err
+			}
+			if len(hits) == 0 {
+				return fmt.Errorf("%q not found in %v indexed posts", query, ib.Index.Len())
+			}
+			showSearchResults(query, hits)
+		case "quit":
+			app.Stop()
+		}
+
+		return nil
+	}
+
+	commandVisible := false
+	showCommandLine := func(visible bool) {
+		commandVisible = visible
+		cmdLine.SetText("")
+		if visible {
+			bottom.SwitchToPage("command")
+			app.SetFocus(cmdLine)
+		} else {
+			// фокус возвращается до переключения, иначе он перейдет к строке состояния
 			app.SetFocus(widgets[widgetFocus])
+			bottom.SwitchToPage("status")
+		}
+	}
+	cmdLine.SetAutocompleteFunc(func(text string) []string {
+		return completeCommand(text, ib.BoardIDs())
+	})
+	cmdLine.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			line := cmdLine.GetText()
+			showCommandLine(false)
+			if strings.TrimSpace(line) == "" {
+				return
+			}
+			if err := executeCommand(line); err != nil {
+				Warnf("%v: %v", strings.TrimSpace(line), err)
+			}
+		case tcell.KeyEscape:
+			showCommandLine(false)
+		case tcell.KeyTab:
+			cmdLine.SetText(completeLine(cmdLine.GetText(), ib.BoardIDs()))
+		}
+	})
+
+	// в дереве досок в избранное добавляется выбранная доска, в других панелях
+	// доска открытого треда или списка тредов
+	toggleFavorite := func() {
+		board := listBoardID
+		switch {
+		case widgetFocus == 0:
+			node := bs.GetCurrentNode()
+			if node == nil || node.GetReference() == nil {
+				return
+			}
+			board = node.GetReference().(string)
+		case widgetFocus == 2 && threadID != 0:
+			board = boardID
+		}
+		if board == "" {
+			return
+		}
+
+		// выбранный узел избранного исчезнет из дерева
+		for _, node := range favNode.GetChildren() {
+			if node == bs.GetCurrentNode() {
+				bs.SetCurrentNode(favNode)
+			}
+		}
+
+		message := fmt.Sprintf("/%v/ убрана из избранного", board)
+		if fav.Toggle(board) {
+			message = fmt.Sprintf("/%v/ добавлена в избранное", board)
+		}
+		fillFavorites(favNode, fav, &ib, boardFilter.GetText())
+		if colorMode != "" {
+			setWidgetColors(colors, bs)
+		}
+		statusBar.SetMessage(LogEntry{Time: time.Now(), Level: LevelInfo, Message: message})
+
+		if err := fav.Save(); err != nil {
+			Warnf("can't save favorites: %v", err)
+		}
+	}
+
+	// дерево досок фильтруется по мере ввода запроса, Enter открывает доску,
+	// если она определена однозначно, иначе переходит к найденным доскам
+	boardFilter.SetFocusFunc(func() { widgetFocus = 0 })
+	boardFilter.SetChangedFunc(func(text string) {
+		favNode = buildBoardTree(bs, &ib, fav, strings.TrimSpace(text))
+		if colorMode != "" {
+			setWidgetColors(colors, bs)
+		}
+	})
+	boardFilter.SetDoneFunc(func(key tcell.Key) {
+		query := strings.TrimSpace(boardFilter.GetText())
+
+		switch key {
+		case tcell.KeyEnter:
+			board := strings.Trim(query, "/")
+			if _, ok := ib.Boards[board]; !ok {
+				var found []string
+				for _, id := range ib.BoardIDs() {
+					if boardMatches(id, ib.Boards[id].Name, query) {
+						found = append(found, id)
+					}
+				}
+				if len(found) != 1 {
+					focusPanel(0)
+					return
+				}
+				board = found[0]
+			}
+
+			boardFilter.SetText("")
+			selectBoardNode(bs, board, favNode)
+			if err := openBoard(board); err != nil {
+				Warnf("%v", err)
+			}
+		case tcell.KeyEscape:
+			boardFilter.SetText("")
+			focusPanel(0)
+		case tcell.KeyTab, tcell.KeyBacktab:
+			focusPanel(0)
+		}
+	})
+
+	// switchThreadOrder переупорядочивает список тредов без загрузки, выбранный
+	// тред остается выбранным
+	switchThreadOrder := func() {
+		orderIndex = (orderIndex + 1) % len(threadOrders)
+		order := threadOrders[orderIndex]
+		statusBar.SetMessage(LogEntry{Time: time.Now(), Level: LevelInfo, Message: "Треды " + order.Name})
+
+		if listBoardID == "" {
+			return
+		}
+
+		ib.SortThreads(listBoardID, order.Order)
+		reshowThreadsList()
+	}
+
+	// toggleHidden показывает скрытые фильтрами посты открытого треда или
+	// треды списка, или снова скрывает их
+	toggleHidden := func() {
+		if widgetFocus == 2 && threadID != 0 {
+			state := threadState()
+			state.ShowHidden = !state.ShowHidden
+			showThread()
+			return
+		}
+
+		showHiddenThreads = !showHiddenThreads
+		if listBoardID != "" {
+			reshowThreadsList()
+		}
+	}
+
+	// после изменения правил они сохраняются и применяются к списку и треду
+	showKillfile := func(visible bool) {
+		killfileVisible = visible
+		togglePage("killfile", visible)
+		if visible {
+			killfileEditor.Refresh()
+			app.SetFocus(killfileEditor)
+		}
+	}
+	killfileEditor.SetChangedFunc(func() {
+		if err := killfile.Save(); err != nil {
+			Warnf("can't save killfile: %v", err)
+		}
+
+		if listBoardID != "" {
+			applyHiding()
+			reshowThreadsList()
+		}
+		if threadID != 0 {
+			showThread()
+		}
+	})
+
+	saveHidden := func() {
+		if err := hidden.Save(); err != nil {
+			Warnf("can't save hidden threads and posts: %v", err)
+		}
+	}
+
+	// hideCurrent скрывает выбранный тред списка или пост открытого треда,
+	// а показанный клавишей show_hidden скрытый возвращает
+	hideCurrent := func() {
+		var board, message string
+		var item HiddenItem
+
+		switch {
+		case widgetFocus == 2 && threadID != 0:
+			anchor := tv.CurrentAnchor(func(name string) bool {
+				_, err := ParsePostID(name)
+				return err == nil
+			})
+			postID, err := ParsePostID(anchor)
+			if err != nil {
+				return
+			}
+			board = boardID
+			item = HiddenItem{Post: postID, Title: hiddenPostTitle(ib.Boards[board].Posts[postID])}
+			message = fmt.Sprintf("Пост %v", postID)
+		case widgetFocus == 1 && listBoardID != "":
+			current := tl.GetCurrentItem()
+			if current >= len(listThreads) {
+				return
+			}
+			board = listBoardID
+			thID := listThreads[current]
+			item = HiddenItem{Post: thID, Thread: true, Title: threadSubject(ib.Boards[board], thID)}
+			message = fmt.Sprintf("Тред %v", thID)
+		default:
+			return
+		}
+
+		item.Time = time.Now()
+		if hidden.Toggle(board, item) {
+			message += " скрыт"
+		} else {
+			message += " больше не скрыт"
+		}
+		statusBar.SetMessage(LogEntry{Time: time.Now(), Level: LevelInfo, Message: message})
+		saveHidden()
+
+		if item.Thread {
+			current := tl.GetCurrentItem()
+			applyHiding()
+			showThreadsList()
+			if current >= tl.GetItemCount() {
+				current = tl.GetItemCount() - 1
+			}
+			tl.SetCurrentItem(current)
+		} else {
+			scroll := tv.Scroll()
+			showThread()
+			tv.SetScroll(scroll)
+		}
+	}
+
+	// в списке скрытого Enter возвращает выбранное
+	var showHiddenList func(visible bool)
+	fillHiddenList := func() {
+		items := hidden.Items()
+		current := hiddenList.GetCurrentItem()
+
+		hiddenList.Clear()
+		hiddenList.SetTitle(fmt.Sprintf(" Скрытое (%v): Enter - вернуть ", len(items)))
+		for _, ref := range items {
+			ref := ref
+			kind := "пост"
+			if ref.Thread {
+				kind = "тред"
+			}
+			secondary := fmt.Sprintf("/%v/ %v %v · скрыт %v", ref.Board, kind, ref.Post, ref.Time.Format("02.01.2006 15:04"))
+			hiddenList.AddItem(tview.Escape(ref.Title), secondary, 0, func() {
+				hidden.Unhide(ref.Board, ref.Post, ref.Thread)
+				saveHidden()
+				if ref.Board == listBoardID {
+					applyHiding()
+					reshowThreadsList()
+				}
+				if ref.Board == boardID && threadID != 0 {
+					showThread()
+				}
+				showHiddenList(true)
+			})
+		}
+
+		if current < hiddenList.GetItemCount() {
+			hiddenList.SetCurrentItem(current)
+		}
+	}
+	showHiddenList = func(visible bool) {
+		hiddenVisible = visible
+		togglePage("hidden", visible)
+		if visible {
+			fillHiddenList()
+			app.SetFocus(hiddenList)
+		}
+	}
+
+	// ответ отправляется в открытый тред со ссылкой на выбранный пост, в
+	// остальных панелях создается новый тред на доске списка
+	showCompose := func(visible bool) {
+		composeVisible = visible
+		togglePage("compose", visible)
+		if !visible {
+			focusPanel(widgetFocus)
+			return
+		}
+
+		switch {
+		case widgetFocus == 2 && threadID != 0:
+			anchor := tv.CurrentAnchor(func(name string) bool {
+				_, err := ParsePostID(name)
+				return err == nil
+			})
+			quote, _ := ParsePostID(anchor)
+			compose.Open(boardID, threadID, quote)
+		case listBoardID != "":
+			compose.Open(listBoardID, 0, 0)
+		default:
+			composeVisible = false
+			togglePage("compose", false)
+			Warnf("no board to post to")
+			return
+		}
+		app.SetFocus(compose)
+	}
+	compose.SetCancelFunc(func() { showCompose(false) })
+	compose.SetEditFunc(func(text string) (edited string, err error) {
+		app.Suspend(func() { edited, err = editInEditor(text) })
+		return edited, err
+	})
+	// sendPost отправляет пост с заполненным ответом на капчу
+	sendPost := func(p PostForm) {
+		num, err := SubmitPost(p)
+		if err != nil {
+			// вход по пасскоду выполняется заново при следующей отправке
+			var pe *PostingError
+			if errors.As(err, &pe) && pe.CaptchaRequired() {
+				captcha.Invalidate()
+			}
+			Warnf("posting to /%v/: %v", p.Board, err)
+			return
+		}
+
+		compose.ResetDraft()
+		showCompose(false)
+		if p.Thread == 0 {
+			statusBar.SetMessage(LogEntry{Time: time.Now(), Level: LevelInfo, Message: fmt.Sprintf("Создан тред %v", num)})
+			if err := openThread(p.Board, num); err != nil {
+				Warnf("%v", err)
+			}
+			return
+		}
+
+		statusBar.SetMessage(LogEntry{Time: time.Now(), Level: LevelInfo, Message: fmt.Sprintf("Отправлен пост %v", num)})
+		if p.Board == boardID && p.Thread == threadID {
+			if err := ib.UpdateThread(boardID, threadID); err != nil {
+				Warnf("%v", err)
+				return
+			}
+			showThread()
+			tv.ScrollToAnchor(fmt.Sprint(num))
+		}
+	}
+
+	// пост ждет ответа на капчу, Esc возвращает к форме поста
+	var pending PostForm
+	showCaptcha := func(visible bool) {
+		captchaVisible = visible
+		togglePage("captcha", visible)
+		if visible {
+			app.SetFocus(captchaView)
+		} else {
+			captchaView.Close()
+			app.SetFocus(compose)
+		}
+	}
+	// challenge запрашивает капчу для поста, если капча не нужна, пост
+	// отправляется сразу
+	challenge := func() {
+		c, err := captcha.Challenge(pending.Board, pending.Thread)
+		if err != nil {
+			Warnf("%v", err)
+			return
+		}
+		if c == nil {
+			if captchaVisible {
+				showCaptcha(false)
+			}
+			pending.Captcha = captcha.Fields(nil, "")
+			sendPost(pending)
+			return
+		}
+
+		captchaView.Show(c)
+		showCaptcha(true)
+	}
+	compose.SetSubmitFunc(func(p PostForm) {
+		if err := p.Validate(); err != nil {
+			Warnf("posting to /%v/: %v", p.Board, err)
+			return
+		}
+		pending = p
+		challenge()
+	})
+	captchaView.SetAnswerFunc(func(c *Captcha, answer string) {
+		pending.Captcha = captcha.Fields(c, answer)
+		showCaptcha(false)
+		sendPost(pending)
+	}).
+		SetReloadFunc(challenge).
+		SetCancelFunc(func() { showCaptcha(false) })
+
+	toggleListMode := func() {
+		if listMode == ListDetailed {
+			listMode = ListCompact
+		} else {
+			listMode = ListDetailed
+		}
+		tl.ShowSecondaryText(listMode == ListDetailed)
+		updateThreadTitles()
+	}
+
+	// список тредов фильтруется по мере ввода, неверное выражение не меняет список
+	threadFilter.SetFocusFunc(func() { widgetFocus = 1 })
+	threadFilter.SetChangedFunc(func(text string) {
+		listFilter = nil
+		if text = strings.TrimSpace(text); text != "" {
+			re, err := parseFilter(text)
+			if err != nil {
+				statusBar.SetMessage(LogEntry{Time: time.Now(), Level: LevelWarn, Message: err.Error()})
+				return
+			}
+			listFilter = re
+		}
+		if listBoardID != "" {
+			showThreadsList()
+		}
+	})
+	threadFilter.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			threadFilter.SetText("")
+		}
+		focusPanel(1)
+	})
+
+	// действия, относящиеся к треду, работают только в его панели
+	threadAction := func(action Action) bool {
+		if widgetFocus != 2 {
+			return false
+		}
+
+		switch action {
+		case ActToggleTree:
+			if boardID == "" {
+				return false
+			}
+			state := threadState()
+			if state.Mode == ModeTree {
+				state.Mode = ModeChrono
+			} else {
+				state.Mode = ModeTree
+			}
+			showThread()
+			tv.ScrollToBeginning()
+		case ActToggleSpoilers:
+			tv.ToggleSpoilers()
+		case ActToggleHyphenate:
+			tv.ToggleHyphenation()
+		case ActToggleJustify:
+			tv.ToggleJustify()
+		case ActUnread:
+			tv.ScrollToAnchor(unreadAnchor)
+		default:
+			return false
+		}
+		return true
+	}
+
+	// размеры панелей меняются перетаскиванием их границ
+	dividers := []*dividerDrag{
+		{flex: flex, item: boards, min: 10},
+		{flex: content, item: tl, vertical: true, min: 3},
+	}
+	app.SetMouseCapture(func(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+		x, y := event.Position()
+		if commandVisible && action == tview.MouseLeftClick && !bottom.InRect(x, y) {
+			showCommandLine(false)
+		}
+		for _, d := range dividers {
+			if d.handle(action, x, y) {
+				return nil, action
+			}
+		}
+		return event, action
+	})
+
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// в полях ввода клавиши не переназначаются
+		if _, ok := app.GetFocus().(*tview.InputField); ok {
+			return event
+		}
+
+		action := keymap.Action(event)
+
+		if searchVisible {
+			switch action {
+			case ActBack:
+				showSearch(false)
+			case ActQuit:
+				app.Stop()
+			default:
+				if key, ok := actionKeys[action]; ok {
+					return tcell.NewEventKey(key, 0, tcell.ModNone)
+				}
+				return event
+			}
+			return nil
+		}
+
+		if hiddenVisible {
+			switch action {
+			case ActBack, ActHiddenList:
+				showHiddenList(false)
+			case ActQuit:
+				app.Stop()
+			default:
+				if key, ok := actionKeys[action]; ok {
+					return tcell.NewEventKey(key, 0, tcell.ModNone)
+				}
+				return event
+			}
+			return nil
+		}
+
+		if captchaVisible {
+			switch action {
+			case ActBack:
+				showCaptcha(false)
+			case ActQuit:
+				app.Stop()
+			default:
+				return event
+			}
+			return nil
+		}
+
+		if composeVisible {
+			switch action {
+			case ActBack:
+				showCompose(false)
+			case ActQuit:
+				app.Stop()
+			default:
+				return event
+			}
+			return nil
+		}
+
+		if killfileVisible {
+			switch action {
+			case ActBack:
+				if !killfileEditor.Back() {
+					showKillfile(false)
+				}
+			case ActQuit:
+				app.Stop()
+			default:
+				if key, ok := actionKeys[action]; ok {
+					return tcell.NewEventKey(key, 0, tcell.ModNone)
+				}
+				return event
+			}
+			return nil
+		}
+
+		if helpVisible {
+			switch action {
+			case ActHelp, ActBack:
+				helpVisible = false
+				togglePage("help", false)
+			case ActQuit:
+				app.Stop()
+			}
+			return nil
+		}
+
+		switch action {
+		case ActNextPanel:
+			focusPanel(widgetFocus + 1)
+		case ActPrevPanel:
+			focusPanel(widgetFocus - 1)
+		case ActBack:
+			if widgetFocus == 2 && tv.HasPopups() {
+				return tcell.NewEventKey(tcell.KeyEsc, 0, tcell.ModNone)
+			}
+			focusPanel(widgetFocus - 1)
+		case ActHelp:
+			helpVisible = true
+			togglePage("help", true)
+		case ActDebug:
+			debugView.visible = !debugView.visible
+			if debugView.visible {
+				debugView.Update()
+			}
+			togglePage("debug", debugView.visible)
+		case ActQuit:
+			app.Stop()
+		case ActRefresh:
+			refresh()
+		case ActWatch:
+			toggleWatch()
+		case ActFavorite:
+			toggleFavorite()
+		case ActThreadOrder:
+			switchThreadOrder()
+		case ActListDetails:
+			toggleListMode()
+		case ActShowHidden:
+			toggleHidden()
+		case ActKillfile:
+			showKillfile(true)
+		case ActHide:
+			hideCurrent()
+		case ActHiddenList:
+			showHiddenList(true)
+		case ActCompose:
+			showCompose(true)
+		case ActFilterThreads:
+			widgetFocus = 1
+			app.SetFocus(threadFilter)
+		case ActFindBoard:
+			widgetFocus = 0
+			app.SetFocus(boardFilter)
+		case ActNextTheme:
+			themeIndex = (themeIndex + 1) % len(themes)
+			applyTheme()
+		case ActNextTab:
+			if n := len(tabs.Tabs); n > 0 {
+				showTab((tabs.Current + 1) % n)
+			}
+		case ActPrevTab:
+			if n := len(tabs.Tabs); n > 0 && tabs.Current > 0 {
+				showTab(tabs.Current - 1)
+			} else if n > 0 {
+				showTab(n - 1)
+			}
+		case ActCloseTab:
+			closeTab()
+		case ActCommand:
+			showCommandLine(true)
+		case ActFind:
+			showCommandLine(true)
+			cmdLine.SetText("find ")
+		case "":
+			return event
 		default:
+			if n, ok := tabNumber(action); ok {
+				showTab(n - 1)
+				return nil
+			}
+			if threadAction(action) {
+				return nil
+			}
+			if key, ok := actionKeys[action]; ok {
+				return tcell.NewEventKey(key, 0, tcell.ModNone)
+			}
 			return event
 		}
 
 		return nil
 	})
 
+	// список тредов открывается до вкладки, иначе его превью заменит тред вкладки
+	current := tabs.Current
+	if board := strings.Trim(cfg.StartBoard, "/"); board != "" {
+		if err := openBoard(board); err != nil {
+			Warnf("start board: %v", err)
+		}
+	}
+
+	// открытая в прошлый раз вкладка
+	if current >= 0 {
+		showTab(current)
+	} else {
+		updateTabBar()
+	}
+
 	if err := app.Run(); err != nil {
 		panic(err)
 	}
+
+	saveTabScroll()
+	if err := tabs.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "can't save tabs: %v\n", err)
+	}
+
+	if indexFile != "" {
+		if err := ib.Index.Save(indexFile); err != nil {
+			fmt.Fprintf(os.Stderr, "can't save search index: %v\n", err)
+		}
+	}
 }
@@ -0,0 +1,67 @@
+package main
+
+import "github.com/rivo/tview"
+
+// dividerDrag меняет размер панели перетаскиванием мышью ее правой или нижней
+// границы
+type dividerDrag struct {
+	flex     *tview.Flex     // контейнер панели
+	item     tview.Primitive // панель перед границей
+	vertical bool            // граница нижняя, меняется высота панели
+	min      int             // наименьший размер панели и остатка контейнера
+	active   bool
+}
+
+// onDivider сообщает, находится ли точка на границе панели
+func (d *dividerDrag) onDivider(x, y int) bool {
+	ix, iy, iw, ih := d.item.GetRect()
+	if d.vertical {
+		return y == iy+ih-1 && x >= ix && x < ix+iw
+	}
+
+	return x == ix+iw-1 && y >= iy && y < iy+ih
+}
+
+// handle обрабатывает событие мыши, возвращает true, если событие относится
+// к перетаскиванию
+func (d *dividerDrag) handle(action tview.MouseAction, x, y int) bool {
+	switch action {
+	case tview.MouseLeftDown:
+		if d.onDivider(x, y) {
+			d.active = true
+			return true
+		}
+	case tview.MouseMove:
+		if d.active {
+			d.resize(x, y)
+			return true
+		}
+	case tview.MouseLeftUp, tview.MouseLeftClick:
+		if d.active {
+			d.active = false
+			return true
+		}
+	}
+
+	return false
+}
+
+// resize задает панели постоянный размер до точки x, y
+func (d *dividerDrag) resize(x, y int) {
+	fx, fy, fw, fh := d.flex.GetRect()
+	ix, iy, _, _ := d.item.GetRect()
+
+	size, total := x-ix+1, fw-(ix-fx)
+	if d.vertical {
+		size, total = y-iy+1, fh-(iy-fy)
+	}
+
+	if size > total-d.min {
+		size = total - d.min
+	}
+	if size < d.min {
+		size = d.min
+	}
+
+	d.flex.ResizeItem(d.item, size, 0)
+}
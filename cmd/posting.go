@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPostFiles наибольшее число файлов в посте
+const maxPostFiles = 4
+
+// PostForm пост для отправки на сайт
+type PostForm struct {
+	Board   string
+	Thread  PostID // 0 - новый тред
+	Name    string
+	Email   string // sage - не поднимать тред
+	Subject string
+	Comment string
+	Files   []string // пути прикрепляемых файлов
+
+	Captcha map[string]string // поля ответа на капчу
+}
+
+// Validate проверяет пост до отправки
+func (p *PostForm) Validate() error {
+	if p.Board == "" {
+		return errors.New("board is not set")
+	}
+	if strings.TrimSpace(p.Comment) == "" && len(p.Files) == 0 {
+		return errors.New("post has neither comment nor files")
+	}
+	if len(p.Files) > maxPostFiles {
+		return fmt.Errorf("too many files: %v, at most %v allowed", len(p.Files), maxPostFiles)
+	}
+
+	for _, f := range p.Files {
+		st, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		if st.IsDir() {
+			return fmt.Errorf("%v is a directory", f)
+		}
+	}
+
+	return nil
+}
+
+// writeMultipart записывает поля и файлы поста в формате multipart/form-data,
+// возвращает тип содержимого с границей частей
+func (p *PostForm) writeMultipart(w io.Writer) (string, error) {
+	mw := multipart.NewWriter(w)
+
+	fields := []struct{ name, value string }{
+		{"task", "post"},
+		{"board", p.Board},
+		{"thread", strconv.FormatInt(int64(p.Thread), 10)},
+		{"name", p.Name},
+		{"email", p.Email},
+		{"subject", p.Subject},
+		{"comment", p.Comment},
+	}
+	for _, f := range fields {
+		if err := mw.WriteField(f.name, f.value); err != nil {
+			return "", err
+		}
+	}
+
+	names := make([]string, 0, len(p.Captcha))
+	for name := range p.Captcha {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := mw.WriteField(name, p.Captcha[name]); err != nil {
+			return "", err
+		}
+	}
+
+	for _, filename := range p.Files {
+		if err := writeFilePart(mw, "formimages[]", filename); err != nil {
+			return "", err
+		}
+	}
+
+	return mw.FormDataContentType(), mw.Close()
+}
+
+// writeFilePart добавляет содержимое файла в поле field
+func writeFilePart(mw *multipart.Writer, field, filename string) error {
+	fl, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fl.Close()
+
+	part, err := mw.CreateFormFile(field, filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, fl)
+	return err
+}
+
+// _postingResult ответ сайта на отправку поста: Status "OK" и номер поста
+// Num для ответа, "Redirect" и номер треда Target для нового треда, при
+// ошибке - код Error и описание Reason
+type _postingResult struct {
+	Error  json.RawMessage `json:"Error"`
+	Reason string          `json:"Reason"`
+	Status string          `json:"Status"`
+	Num    json.Number     `json:"Num"`
+	Target json.Number     `json:"Target"`
+}
+
+// _postingError ошибка в ответе новой версии API
+type _postingError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorCaptcha код ошибки сайта о неверной или не введенной капче, так же
+// сайт отвечает на пост с пасскодом после окончания сессии
+const errorCaptcha = -5
+
+// PostingError ошибка, которую вернул сайт при отправке поста
+type PostingError struct {
+	Code    int    // 0, если код не число
+	Raw     string // код в ответе сайта
+	Message string
+}
+
+func (e *PostingError) Error() string {
+	return fmt.Sprintf("server error %v: %v", e.Raw, e.Message)
+}
+
+// CaptchaRequired сообщает, что сайт не принял пост без капчи
+func (e *PostingError) CaptchaRequired() bool {
+	return e.Code == errorCaptcha
+}
+
+// parsePostingResult возвращает номер отправленного поста или нового треда
+// либо ошибку с сообщением сайта
+func parsePostingResult(data []byte) (PostID, error) {
+	var res _postingResult
+	if err := json.Unmarshal(data, &res); err != nil {
+		return 0, fmt.Errorf("invalid JSON: %v: %q", err, jsonContext(data, 0))
+	}
+
+	if e := bytes.TrimSpace(res.Error); len(e) > 0 && string(e) != "null" && string(e) != "0" {
+		var pe _postingError
+		if json.Unmarshal(e, &pe) == nil && pe.Message != "" {
+			return 0, &PostingError{Code: pe.Code, Raw: strconv.Itoa(pe.Code), Message: pe.Message}
+		}
+		code, _ := strconv.Atoi(string(e))
+		return 0, &PostingError{Code: code, Raw: string(e), Message: res.Reason}
+	}
+
+	num := res.Num
+	if res.Status == "Redirect" {
+		num = res.Target
+	}
+	n, err := num.Int64()
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("unexpected response status %q", res.Status)
+	}
+
+	return PostID(n), nil
+}
+
+// postData отправляет данные POST-запросом, ответ с кодом, отличным от 200,
+// считается ошибкой
+func postData(url, contentType string, body io.Reader) ([]byte, error) {
+	req := RequestEntry{Time: time.Now(), Method: http.MethodPost, URL: url}
+	defer func() {
+		req.Duration = time.Since(req.Time)
+		logger.Request(req)
+	}()
+
+	resp, err := httpClient.Post(url, contentType, body)
+	if err != nil {
+		req.Err = err
+		return nil, err
+	}
+	defer resp.Body.Close()
+	req.Status = resp.StatusCode
+
+	data, err := ioutil.ReadAll(resp.Body)
+	req.Size = len(data)
+	if err != nil {
+		req.Err = err
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	return data, nil
+}
+
+// SubmitPost отправляет пост, возвращает номер поста, а для нового треда -
+// номер треда
+func SubmitPost(p PostForm) (PostID, error) {
+	if stubFetcher {
+		return 0, errors.New("posting is not available with stub fetcher")
+	}
+	if err := p.Validate(); err != nil {
+		return 0, err
+	}
+
+	var body bytes.Buffer
+	contentType, err := p.writeMultipart(&body)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := postData(fmt.Sprintf("%v/makaba/posting.fcgi?json=1", baseURL), contentType, &body)
+	if err != nil {
+		return 0, err
+	}
+
+	return parsePostingResult(data)
+}
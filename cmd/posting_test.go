@@ -0,0 +1,171 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withServer направляет запросы к сайту на тестовый сервер с обработчиком handler
+func withServer(t *testing.T, handler http.Handler) {
+	srv := httptest.NewServer(handler)
+	oldURL, oldJar, oldStub := baseURL, httpClient.Jar, stubFetcher
+	baseURL = srv.URL
+	httpClient.Jar, _ = cookiejar.New(nil)
+	stubFetcher = false
+
+	t.Cleanup(func() {
+		srv.Close()
+		baseURL, httpClient.Jar, stubFetcher = oldURL, oldJar, oldStub
+	})
+}
+
+// respond отвечает на запрос кодом status и телом body
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestSubmitPostForm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boarding-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{"cat.jpg": "jpeg data", "dog.png": "png data"}
+	var paths []string
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	withServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/makaba/posting.fcgi" || r.URL.Query().Get("json") != "1" {
+			t.Errorf("unexpected request %v %v", r.Method, r.URL)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("invalid form: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		want := map[string][]string{
+			"task":             {"post"},
+			"board":            {"b"},
+			"thread":           {"123"},
+			"name":             {"Аноним"},
+			"email":            {"sage"},
+			"subject":          {""},
+			"comment":          {">>124\nТекст"},
+			"captcha_type":     {"2chcaptcha"},
+			"2chcaptcha_id":    {"abc"},
+			"2chcaptcha_value": {"123456"},
+		}
+		if got := r.MultipartForm.Value; !reflect.DeepEqual(got, want) {
+			t.Errorf("fields = %v, want %v", got, want)
+		}
+
+		parts := r.MultipartForm.File["formimages[]"]
+		if len(parts) != len(files) {
+			t.Errorf("%v files, want %v", len(parts), len(files))
+			http.Error(w, "wrong files", http.StatusInternalServerError)
+			return
+		}
+		for _, part := range parts {
+			fl, err := part.Open()
+			if err != nil {
+				t.Errorf("can't open file %v: %v", part.Filename, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			data, _ := ioutil.ReadAll(fl)
+			fl.Close()
+			if string(data) != files[part.Filename] {
+				t.Errorf("file %v = %q, want %q", part.Filename, data, files[part.Filename])
+			}
+		}
+
+		w.Write([]byte(`{"Error":null,"Status":"OK","Num":125}`))
+	}))
+
+	num, err := SubmitPost(PostForm{
+		Board:   "b",
+		Thread:  123,
+		Name:    "Аноним",
+		Email:   "sage",
+		Comment: ">>124\nТекст",
+		Files:   paths,
+		Captcha: dvachCaptcha{}.Fields(&Captcha{Type: "2chcaptcha", ID: "abc"}, "123456"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 125 {
+		t.Errorf("post number = %v, want 125", num)
+	}
+}
+
+func TestSubmitPostResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		num    PostID
+		err    string
+	}{
+		{"reply", http.StatusOK, `{"Error":null,"Status":"OK","Num":125}`, 125, ""},
+		{"new thread", http.StatusOK, `{"Error":null,"Status":"Redirect","Target":777}`, 777, ""},
+		{"error code", http.StatusOK, `{"Error":-5,"Reason":"Капча невалидна"}`, 0, "server error -5: Капча невалидна"},
+		{"error object", http.StatusOK, `{"error":{"code":-8,"message":"Постинг запрещен"},"result":0}`, 0,
+			"server error -8: Постинг запрещен"},
+		{"no number", http.StatusOK, `{"Error":null,"Status":"OK"}`, 0, `unexpected response status "OK"`},
+		{"not json", http.StatusOK, `<html></html>`, 0, "invalid JSON"},
+		{"http error", http.StatusServiceUnavailable, `{}`, 0, "unexpected status 503 Service Unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withServer(t, respond(tt.status, tt.body))
+
+			num, err := SubmitPost(PostForm{Board: "b", Comment: "Текст"})
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+			if num != tt.num {
+				t.Errorf("post number = %v, want %v", num, tt.num)
+			}
+		})
+	}
+}
+
+func TestSubmitPostInvalid(t *testing.T) {
+	withServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("invalid post is sent")
+	}))
+
+	for _, p := range []PostForm{
+		{Comment: "без доски"},
+		{Board: "b", Comment: " \n"},
+		{Board: "b", Files: []string{"/nonexistent/file.jpg"}},
+		{Board: "b", Files: []string{"1", "2", "3", "4", "5"}},
+	} {
+		if _, err := SubmitPost(p); err == nil {
+			t.Errorf("post %+v is accepted", p)
+		}
+	}
+}
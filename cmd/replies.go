@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/2chboarding/boarding/richtext"
+	"golang.org/x/net/html"
+)
+
+// ReplyLinks возвращает номера постов доски boardID, на которые ссылается
+// комментарий. Ссылки на посты в разметке 2ch имеют атрибут data-num, ссылки
+// на другие доски пропускаются
+func ReplyLinks(boardID, comment string) ThreadPosts {
+	var links ThreadPosts
+
+	richtext.ParseHTML(comment, func(tt html.TokenType, token string, attrs []richtext.TagAttr) {
+		if tt != html.StartTagToken || token != "a" {
+			return
+		}
+
+		for _, attr := range attrs {
+			if attr.Name != "data-num" {
+				continue
+			}
+
+			link := richtext.ParseLink(attrs)
+			if link.Post != 0 && (link.Board == "" || link.Board == boardID) {
+				links = append(links, PostID(link.Post))
+			}
+			break
+		}
+	})
+
+	return links
+}
+
+// Add добавляет ответ reply к посту target, повторы игнорируются
+func (r RepliesMap) Add(target, reply PostID) {
+	replies := r[target]
+
+	i := sort.Search(len(replies), func(i int) bool { return replies[i] >= reply })
+	if i < len(replies) && replies[i] == reply {
+		return
+	}
+
+	replies = append(replies, 0)
+	copy(replies[i+1:], replies[i:])
+	replies[i] = reply
+	r[target] = replies
+}
+
+// Remove убирает ответ reply к посту target
+func (r RepliesMap) Remove(target, reply PostID) {
+	replies := r[target]
+
+	i := sort.Search(len(replies), func(i int) bool { return replies[i] >= reply })
+	if i == len(replies) || replies[i] != reply {
+		return
+	}
+
+	replies = append(replies[:i], replies[i+1:]...)
+	if len(replies) == 0 {
+		delete(r, target)
+		return
+	}
+	r[target] = replies
+}
+
+// indexReplies заносит ссылки из комментария поста num в обратный индекс
+// доски, ссылки прежнего комментария oldComment измененного поста убираются
+func (ib *ImageBoard) indexReplies(ID string, num PostID, oldComment, comment string) {
+	replies := ib.Boards[ID].Replies
+	if replies == nil {
+		panic("ib.Boards[ID].Replies uninitialized")
+	}
+
+	if oldComment != "" {
+		for _, target := range ReplyLinks(ID, oldComment) {
+			replies.Remove(target, num)
+		}
+	}
+
+	for _, target := range ReplyLinks(ID, comment) {
+		// ссылка поста на самого себя ответом не считается
+		if target != num {
+			replies.Add(target, num)
+		}
+	}
+}
+
+// renderReplies формирует строку со ссылками на ответы к посту
+func renderReplies(boardID string, threadID PostID, replies ThreadPosts) string {
+	result := "Ответы:"
+	for _, r := range replies {
+		result += fmt.Sprintf(
+			` <a href="/%v/res/%v.html#%v" class="post-reply-link" data-num="%v">&gt;&gt;%v</a>`,
+			boardID, threadID, r, r, r)
+	}
+
+	return result
+}
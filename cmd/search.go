@@ -0,0 +1,98 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/tview"
+
+	"github.com/2chboarding/boarding/richtext"
+)
+
+// snippetContext число символов текста перед найденным в описании находки
+const snippetContext = 30
+
+// parseFilter разбирает запрос фильтра или поиска: "/выражение/" -
+// регулярное выражение, иначе подстрока, регистр не учитывается
+func parseFilter(query string) (*regexp.Regexp, error) {
+	if len(query) > 1 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/") {
+		return regexp.Compile("(?i)" + query[1:len(query)-1])
+	}
+
+	return regexp.Compile("(?i)" + regexp.QuoteMeta(query))
+}
+
+// filterThreads возвращает треды доски, в теме или тексте ОП-поста которых
+// есть совпадение с re, без фильтра - все треды
+func filterThreads(board BoardStruct, re *regexp.Regexp) ThreadPosts {
+	if re == nil {
+		return board.ThreadsIndex
+	}
+
+	var threads ThreadPosts
+	for _, thID := range board.ThreadsIndex {
+		post := board.Posts[thID]
+		if re.MatchString(threadSubject(board, thID)) || re.MatchString(richtext.Parse(post.Comment).PlainText()) {
+			threads = append(threads, thID)
+		}
+	}
+
+	return threads
+}
+
+// SearchHit найденный пост
+type SearchHit struct {
+	Board   string
+	Thread  PostID
+	Post    PostID
+	Snippet string // текст поста с разметкой tview, найденное выделено
+}
+
+// SearchPosts ищет посты доски среди загруженных, находки упорядочены по
+// номеру поста, новые сначала
+func (ib *ImageBoard) SearchPosts(boardID string, re *regexp.Regexp) []SearchHit {
+	board := ib.Boards[boardID]
+
+	// посты не знают своего треда
+	threadOf := make(map[PostID]PostID, len(board.Posts))
+	for thID, thread := range board.Threads {
+		for _, postID := range thread.Posts {
+			threadOf[postID] = thID
+		}
+	}
+
+	var hits []SearchHit
+	for postID, post := range board.Posts {
+		thID, ok := threadOf[postID]
+		if !ok {
+			continue
+		}
+
+		text := richtext.Parse(post.Comment).PlainText()
+		if loc := re.FindStringIndex(text); loc != nil {
+			hits = append(hits, SearchHit{boardID, thID, postID, highlightSnippet(text, loc[0], loc[1])})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Post > hits[j].Post })
+	return hits
+}
+
+// highlightSnippet возвращает текст, начиная незадолго до найденного
+// фрагмента text[from:to], фрагмент выделяется инверсией
+func highlightSnippet(text string, from, to int) string {
+	start := from
+	for n := 0; n < snippetContext && start > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+
+	prefix := ""
+	if start > 0 {
+		prefix = "…"
+	}
+
+	return prefix + tview.Escape(text[start:from]) + "[::r]" + tview.Escape(text[from:to]) + "[::-]" + tview.Escape(text[to:])
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// loadState читает JSON файла name из каталога состояния в v, отсутствие
+// файла не ошибка, v тогда не меняется
+func loadState(name string, v interface{}) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// saveState записывает v в JSON файл name каталога состояния
+func saveState(name string, v interface{}) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, name), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// writeFileAtomic записывает файл через временный файл в том же каталоге,
+// который затем переименовывается, так что при сбое записи прежний файл
+// остается целым
+func writeFileAtomic(filename string, write func(w io.Writer) error) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	fl, err := ioutil.TempFile(dir, filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// после переименования удалять уже нечего
+	defer os.Remove(fl.Name())
+
+	bw := bufio.NewWriter(fl)
+	if err := write(bw); err != nil {
+		fl.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		fl.Close()
+		return err
+	}
+	if err := fl.Sync(); err != nil {
+		fl.Close()
+		return err
+	}
+	if err := fl.Close(); err != nil {
+		return err
+	}
+	// TempFile создает файл только для владельца
+	if err := os.Chmod(fl.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(fl.Name(), filename)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// statusMessageTime время, в течение которого видно сообщение
+const statusMessageTime = 10 * time.Second
+
+// StatusBar строка состояния: слева открытая доска или тред, справа последний
+// запрос к сайту, вместо него некоторое время видно последнее сообщение
+type StatusBar struct {
+	*tview.Box
+
+	mu          sync.Mutex
+	left, right string
+	message     LogEntry
+
+	style        tcell.Style
+	warningStyle tcell.Style
+}
+
+// NewStatusBar создает строку состояния
+func NewStatusBar() *StatusBar {
+	return &StatusBar{
+		Box:          tview.NewBox(),
+		style:        tcell.StyleDefault.Reverse(true),
+		warningStyle: tcell.StyleDefault.Reverse(true).Bold(true),
+	}
+}
+
+// SetText задает текст левой и правой частей строки
+func (sb *StatusBar) SetText(left, right string) *StatusBar {
+	sb.mu.Lock()
+	sb.left, sb.right = left, right
+	sb.mu.Unlock()
+	return sb
+}
+
+// SetMessage показывает сообщение, предупреждения и ошибки выделяются
+func (sb *StatusBar) SetMessage(message LogEntry) *StatusBar {
+	sb.mu.Lock()
+	if message.Time.After(sb.message.Time) {
+		sb.message = message
+	}
+	sb.mu.Unlock()
+	return sb
+}
+
+// SetColors задает стили строки и предупреждений
+func (sb *StatusBar) SetColors(style, warning tcell.Style) *StatusBar {
+	sb.style = style
+	sb.warningStyle = warning
+	return sb
+}
+
+// Draw рисует строку, сообщение заменяет правую часть и может закрыть левую
+func (sb *StatusBar) Draw(screen tcell.Screen) {
+	sb.Box.Draw(screen)
+	x, y, w, h := sb.GetInnerRect()
+	if h <= 0 {
+		return
+	}
+
+	sb.mu.Lock()
+	left, right, rightStyle := sb.left, sb.right, sb.style
+	if time.Since(sb.message.Time) < statusMessageTime {
+		right = sb.message.Message
+		if sb.message.Level >= LevelWarn {
+			rightStyle = sb.warningStyle
+		}
+	}
+	sb.mu.Unlock()
+
+	for xx := 0; xx < w; xx++ {
+		screen.SetContent(x+xx, y, ' ', nil, sb.style)
+	}
+
+	if w < 4 {
+		return
+	}
+
+	right = runewidth.Truncate(right, w-2, "…")
+	rx := x + w - 1 - runewidth.StringWidth(right)
+	if width := rx - x - 2; width > 0 {
+		printLine(screen, runewidth.Truncate(left, width, "…"), x+1, y, sb.style)
+	}
+	printLine(screen, right, rx, y, rightStyle)
+}
+
+// printLine выводит строку без переноса начиная с x
+func printLine(screen tcell.Screen, text string, x, y int, style tcell.Style) {
+	for _, r := range text {
+		screen.SetContent(x, y, r, nil, style)
+		x += runewidth.RuneWidth(r)
+	}
+}
+
+// plural выбирает форму слова для числа n: 1 пост, 2 поста, 5 постов
+func plural(n int, one, few, many string) string {
+	form := many
+	switch n10, n100 := n%10, n%100; {
+	case n10 == 1 && n100 != 11:
+		form = one
+	case n10 >= 2 && n10 <= 4 && (n100 < 12 || n100 > 14):
+		form = few
+	}
+
+	return fmt.Sprintf("%v %v", n, form)
+}
+
+// requestStatus описывает последний запрос к сайту: ↓ загрузка, ↑ отправка
+func requestStatus(req RequestEntry) string {
+	arrow := "↓"
+	if req.Method == http.MethodPost {
+		arrow = "↑"
+	}
+
+	if req.Err != nil {
+		return fmt.Sprintf("%v %v ошибка", req.Time.Format("15:04:05"), arrow)
+	}
+
+	return fmt.Sprintf("%v %v %v, %.1f КБ за %v", req.Time.Format("15:04:05"), arrow,
+		req.Status, float64(req.Size)/1024, req.Duration.Round(10*time.Millisecond))
+}
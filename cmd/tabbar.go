@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// maxTabWidth наибольшая ширина заголовка вкладки
+const maxTabWidth = 24
+
+// TabBar строка с заголовками вкладок
+type TabBar struct {
+	*tview.Box
+	titles       []string
+	current      int // -1, если ни одна вкладка не выбрана
+	style        tcell.Style
+	currentStyle tcell.Style
+	selectedFunc func(index int)
+
+	// положение заголовков при последней отрисовке для щелчков мышью
+	positions []int
+	first     int
+}
+
+// NewTabBar создает пустую строку вкладок
+func NewTabBar() *TabBar {
+	return &TabBar{
+		Box:          tview.NewBox(),
+		current:      -1,
+		style:        tcell.StyleDefault,
+		currentStyle: tcell.StyleDefault.Reverse(true),
+	}
+}
+
+// SetTabs задает заголовки вкладок и номер текущей
+func (tb *TabBar) SetTabs(titles []string, current int) *TabBar {
+	tb.titles = titles
+	tb.current = current
+	return tb
+}
+
+// SetColors задает стили заголовков вкладок и текущей вкладки
+func (tb *TabBar) SetColors(style, current tcell.Style) *TabBar {
+	tb.style = style
+	tb.currentStyle = current
+	return tb
+}
+
+// SetSelectedFunc задает обработчик выбора вкладки мышью
+func (tb *TabBar) SetSelectedFunc(handler func(index int)) *TabBar {
+	tb.selectedFunc = handler
+	return tb
+}
+
+// label возвращает подпись вкладки i
+func (tb *TabBar) label(i int) string {
+	return fmt.Sprintf(" %v:%v ", i+1, runewidth.Truncate(tb.titles[i], maxTabWidth, "…"))
+}
+
+// Draw рисует заголовки так, чтобы текущая вкладка была видна
+func (tb *TabBar) Draw(screen tcell.Screen) {
+	tb.Box.Draw(screen)
+	x, y, w, h := tb.GetInnerRect()
+	if h <= 0 {
+		return
+	}
+
+	for xx := 0; xx < w; xx++ {
+		screen.SetContent(x+xx, y, ' ', nil, tb.style)
+	}
+
+	// первая видимая вкладка сдвигается, пока текущая не поместится
+	width := func(from, to int) int {
+		total := 0
+		for i := from; i <= to; i++ {
+			total += runewidth.StringWidth(tb.label(i))
+		}
+		return total
+	}
+	tb.first = 0
+	for tb.current >= 0 && tb.first < tb.current && width(tb.first, tb.current) > w {
+		tb.first++
+	}
+
+	tb.positions = tb.positions[:0]
+	xx := 0
+	for i := tb.first; i < len(tb.titles) && xx < w; i++ {
+		style := tb.style
+		if i == tb.current {
+			style = tb.currentStyle
+		}
+
+		tb.positions = append(tb.positions, xx)
+		for _, r := range tb.label(i) {
+			rw := runewidth.RuneWidth(r)
+			if xx+rw > w {
+				break
+			}
+			screen.SetContent(x+xx, y, r, nil, style)
+			xx += rw
+		}
+	}
+}
+
+// MouseHandler выбирает вкладку щелчком
+func (tb *TabBar) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return tb.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		x, y := event.Position()
+		if action != tview.MouseLeftClick || !tb.InRect(x, y) {
+			return false, nil
+		}
+
+		bx, _, _, _ := tb.GetInnerRect()
+		for i, pos := range tb.positions {
+			end := pos + runewidth.StringWidth(tb.label(tb.first+i))
+			if x-bx >= pos && x-bx < end && tb.selectedFunc != nil {
+				tb.selectedFunc(tb.first + i)
+			}
+		}
+
+		return true, nil
+	})
+}
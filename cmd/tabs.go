@@ -0,0 +1,78 @@
+package main
+
+// Tab вкладка открытого треда
+type Tab struct {
+	Board  string `json:"board"`
+	Thread PostID `json:"thread"`
+	Title  string `json:"title"`
+	Scroll int    `json:"scroll"` // первая видимая строка треда
+}
+
+// Tabs открытые вкладки, сохраняются между запусками
+type Tabs struct {
+	Tabs    []Tab `json:"tabs"`
+	Current int   `json:"current"` // -1, если ни одна вкладка не выбрана
+}
+
+// tabsFile файл вкладок в каталоге состояния
+const tabsFile = "tabs.json"
+
+// LoadTabs загружает вкладки
+func LoadTabs() (*Tabs, error) {
+	tabs := &Tabs{Current: -1}
+	if err := loadState(tabsFile, tabs); err != nil {
+		return &Tabs{Current: -1}, err
+	}
+	if tabs.Current >= len(tabs.Tabs) {
+		tabs.Current = len(tabs.Tabs) - 1
+	}
+
+	return tabs, nil
+}
+
+// Save записывает вкладки
+func (t *Tabs) Save() error {
+	return saveState(tabsFile, t)
+}
+
+// Find возвращает номер вкладки треда или -1
+func (t *Tabs) Find(boardID string, threadID PostID) int {
+	for i, tab := range t.Tabs {
+		if tab.Board == boardID && tab.Thread == threadID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Open возвращает номер вкладки треда, вкладка добавляется, если ее не было
+func (t *Tabs) Open(tab Tab) int {
+	if i := t.Find(tab.Board, tab.Thread); i >= 0 {
+		return i
+	}
+
+	t.Tabs = append(t.Tabs, tab)
+	return len(t.Tabs) - 1
+}
+
+// Close закрывает вкладку i, текущей становится соседняя вкладка
+func (t *Tabs) Close(i int) {
+	if i < 0 || i >= len(t.Tabs) {
+		return
+	}
+
+	t.Tabs = append(t.Tabs[:i], t.Tabs[i+1:]...)
+	if t.Current > i || t.Current >= len(t.Tabs) {
+		t.Current--
+	}
+}
+
+// CurrentTab возвращает текущую вкладку или nil
+func (t *Tabs) CurrentTab() *Tab {
+	if t.Current < 0 || t.Current >= len(t.Tabs) {
+		return nil
+	}
+
+	return &t.Tabs[t.Current]
+}
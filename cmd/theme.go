@@ -0,0 +1,307 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+
+	"github.com/2chboarding/boarding/richtext"
+)
+
+// ThemeColors цвета элементов интерфейса
+type ThemeColors struct {
+	Background   tcell.Color
+	Text         tcell.Color
+	Secondary    tcell.Color // второстепенный текст и линии дерева
+	Border       tcell.Color
+	Title        tcell.Color
+	Selected     tcell.Color // фон выбранного элемента списка
+	SelectedText tcell.Color
+	Link         tcell.Color
+	Greentext    tcell.Color
+	Spoiler      tcell.Color // фон спойлеров
+	OPMark       tcell.Color
+	Header       tcell.Color // имя в заголовке поста
+	Unread       tcell.Color // отметка непрочитанных постов
+}
+
+// Theme тема оформления в вариантах для терминалов с 24-битным цветом и
+// с палитрой из 256 цветов
+type Theme struct {
+	Name      string
+	TrueColor ThemeColors
+	Palette   ThemeColors
+}
+
+// Режимы цвета
+const (
+	ColorModeAuto      = "auto"
+	ColorModeTrueColor = "truecolor"
+	ColorMode256       = "256"
+	ColorModeMono      = "mono"
+)
+
+// hex сокращение для цветов тем
+func hex(v int32) tcell.Color {
+	return tcell.NewHexColor(v)
+}
+
+// themes встроенные темы в порядке переключения
+var themes = []Theme{
+	{
+		Name: "dark",
+		TrueColor: ThemeColors{
+			Background: hex(0x1c1c1c), Text: hex(0xd0d0d0), Secondary: hex(0x808080),
+			Border: hex(0x5f87af), Title: hex(0xffffff),
+			Selected: hex(0x3a3a3a), SelectedText: hex(0xffffff),
+			Link: hex(0x87d700), Greentext: hex(0x789922), Spoiler: hex(0x4e4e4e),
+			OPMark: hex(0xd75f5f), Header: hex(0x87afd7), Unread: hex(0xffaf00),
+		},
+		Palette: ThemeColors{
+			Background: 234, Text: 252, Secondary: 244,
+			Border: 67, Title: 15,
+			Selected: 237, SelectedText: 15,
+			Link: 112, Greentext: 106, Spoiler: 239,
+			OPMark: 167, Header: 110, Unread: 214,
+		},
+	},
+	{
+		Name: "light",
+		TrueColor: ThemeColors{
+			Background: hex(0xffffff), Text: hex(0x1c1c1c), Secondary: hex(0x6c6c6c),
+			Border: hex(0x5f87af), Title: hex(0x000000),
+			Selected: hex(0xd7d7ff), SelectedText: hex(0x000000),
+			Link: hex(0x005fd7), Greentext: hex(0x5f8700), Spoiler: hex(0xbcbcbc),
+			OPMark: hex(0xaf0000), Header: hex(0x005f87), Unread: hex(0xd75f00),
+		},
+		Palette: ThemeColors{
+			Background: 231, Text: 234, Secondary: 242,
+			Border: 67, Title: 16,
+			Selected: 189, SelectedText: 16,
+			Link: 26, Greentext: 64, Spoiler: 250,
+			OPMark: 124, Header: 24, Unread: 166,
+		},
+	},
+	{
+		Name: "solarized",
+		TrueColor: ThemeColors{
+			Background: hex(0x002b36), Text: hex(0x839496), Secondary: hex(0x586e75),
+			Border: hex(0x268bd2), Title: hex(0x93a1a1),
+			Selected: hex(0x073642), SelectedText: hex(0x93a1a1),
+			Link: hex(0x2aa198), Greentext: hex(0x859900), Spoiler: hex(0x586e75),
+			OPMark: hex(0xdc322f), Header: hex(0xb58900), Unread: hex(0xcb4b16),
+		},
+		Palette: ThemeColors{
+			Background: 234, Text: 246, Secondary: 242,
+			Border: 33, Title: 247,
+			Selected: 235, SelectedText: 247,
+			Link: 37, Greentext: 100, Spoiler: 242,
+			OPMark: 160, Header: 136, Unread: 166,
+		},
+	},
+	{
+		Name: "2ch-classic",
+		TrueColor: ThemeColors{
+			Background: hex(0xeeeeee), Text: hex(0x333333), Secondary: hex(0x888888),
+			Border: hex(0xa0a0a0), Title: hex(0x333333),
+			Selected: hex(0xdddddd), SelectedText: hex(0x333333),
+			Link: hex(0xff6600), Greentext: hex(0x789922), Spoiler: hex(0xbbbbbb),
+			OPMark: hex(0xcc1105), Header: hex(0x117743), Unread: hex(0xaf5f00),
+		},
+		Palette: ThemeColors{
+			Background: 255, Text: 236, Secondary: 102,
+			Border: 247, Title: 236,
+			Selected: 253, SelectedText: 236,
+			Link: 202, Greentext: 106, Spoiler: 250,
+			OPMark: 160, Header: 29, Unread: 130,
+		},
+	},
+}
+
+// monoColors цвета монохромного режима: везде цвета терминала, элементы
+// различаются атрибутами, только выбранный элемент списка виден по цвету
+var monoColors = ThemeColors{
+	Background: tcell.ColorDefault, Text: tcell.ColorDefault, Secondary: tcell.ColorDefault,
+	Border: tcell.ColorDefault, Title: tcell.ColorDefault,
+	Selected: tcell.ColorWhite, SelectedText: tcell.ColorBlack,
+	Link: tcell.ColorDefault, Greentext: tcell.ColorDefault, Spoiler: tcell.ColorDefault,
+	OPMark: tcell.ColorDefault, Header: tcell.ColorDefault, Unread: tcell.ColorDefault,
+}
+
+// findTheme возвращает номер темы с именем name
+func findTheme(name string) (int, bool) {
+	for i, t := range themes {
+		if t.Name == name {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// themeNames возвращает имена встроенных тем
+func themeNames() string {
+	names := make([]string, 0, len(themes))
+	for _, t := range themes {
+		names = append(names, t.Name)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// detectColorMode выбирает режим цвета по настройке и возможностям терминала
+func detectColorMode(mode string, screen tcell.Screen) string {
+	if mode != ColorModeAuto {
+		return mode
+	}
+
+	// https://no-color.org
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return ColorModeMono
+	}
+
+	switch colors := screen.Colors(); {
+	case colors >= 1<<24:
+		return ColorModeTrueColor
+	case colors >= 8:
+		// в терминалах с 8 или 16 цветами tcell подберет ближайшие цвета палитры
+		return ColorMode256
+	default:
+		return ColorModeMono
+	}
+}
+
+// Colors возвращает цвета темы для режима mode
+func (t *Theme) Colors(mode string) ThemeColors {
+	switch mode {
+	case ColorModeTrueColor:
+		return t.TrueColor
+	case ColorMode256:
+		return t.Palette
+	default:
+		return monoColors
+	}
+}
+
+// ThreadStyles возвращает стили текста тредов, в монохромном режиме элементы
+// разметки выделяются атрибутами
+func (c ThemeColors) ThreadStyles(mono bool) richtext.Styles {
+	text := tcell.StyleDefault.Foreground(c.Text).Background(c.Background)
+	fg := tcell.StyleDefault.Foreground
+
+	if mono {
+		return richtext.Styles{
+			Text:      text,
+			Link:      tcell.StyleDefault.Underline(true),
+			Greentext: tcell.StyleDefault.Italic(true),
+			Spoiler:   tcell.StyleDefault.Reverse(true),
+			Header:    tcell.StyleDefault.Bold(true),
+			OPMark:    tcell.StyleDefault.Bold(true).Underline(true),
+			Unread:    tcell.StyleDefault.Reverse(true),
+			Guide:     text.Dim(true),
+		}
+	}
+
+	return richtext.Styles{
+		Text:      text,
+		Link:      fg(c.Link),
+		Greentext: fg(c.Greentext),
+		Spoiler:   tcell.StyleDefault.Background(c.Spoiler),
+		Header:    fg(c.Header).Bold(true),
+		OPMark:    fg(c.OPMark).Bold(true),
+		Unread:    fg(c.Unread).Bold(true),
+		Guide:     text.Foreground(c.Secondary),
+	}
+}
+
+// overrideColors заменяет цвета темы цветами из секции [colors] настроек
+func overrideColors(c ThemeColors, cfg ColorsConfig) ThemeColors {
+	for _, o := range []struct {
+		name  string
+		color *tcell.Color
+	}{
+		{cfg.Link, &c.Link},
+		{cfg.Greentext, &c.Greentext},
+		{cfg.Spoiler, &c.Spoiler},
+	} {
+		if o.name != "" {
+			*o.color, _ = parseColor(o.name)
+		}
+	}
+
+	return c
+}
+
+// setWidgetColors раскрашивает виджеты и задает цвета tview для новых виджетов
+func setWidgetColors(c ThemeColors, widgets ...tview.Primitive) {
+	tview.Styles.PrimitiveBackgroundColor = c.Background
+	tview.Styles.ContrastBackgroundColor = c.Selected
+	tview.Styles.BorderColor = c.Border
+	tview.Styles.TitleColor = c.Title
+	tview.Styles.GraphicsColor = c.Secondary
+	tview.Styles.PrimaryTextColor = c.Text
+	tview.Styles.SecondaryTextColor = c.Secondary
+
+	for _, w := range widgets {
+		switch w := w.(type) {
+		case *tview.TreeView:
+			// выбранный узел рисуется инверсией его цвета, цвет терминала
+			// по умолчанию не инвертируется
+			nodeColor := c.Text
+			if nodeColor == tcell.ColorDefault {
+				nodeColor = c.Selected
+			}
+
+			w.SetGraphicsColor(c.Secondary)
+			if root := w.GetRoot(); root != nil {
+				root.Walk(func(node, parent *tview.TreeNode) bool {
+					node.SetColor(nodeColor)
+					return true
+				})
+			}
+		case *tview.List:
+			w.SetMainTextColor(c.Text).
+				SetSecondaryTextColor(c.Secondary).
+				SetSelectedTextColor(c.SelectedText).
+				SetSelectedBackgroundColor(c.Selected)
+		case *tview.TextView:
+			w.SetTextColor(c.Text)
+		case *tview.InputField:
+			w.SetFieldBackgroundColor(c.Background).
+				SetFieldTextColor(c.Text).
+				SetLabelColor(c.Title)
+		case *tview.Form:
+			w.SetFieldBackgroundColor(c.Selected).
+				SetFieldTextColor(c.SelectedText).
+				SetLabelColor(c.Title).
+				SetButtonBackgroundColor(c.Selected).
+				SetButtonTextColor(c.SelectedText)
+		case *KillfileEditor:
+			setWidgetColors(c, w.list, w.form)
+		case *CaptchaView:
+			setWidgetColors(c, w.image, w.form)
+		case *StatusBar:
+			style := tcell.StyleDefault.Foreground(c.SelectedText).Background(c.Selected)
+			warning := style.Bold(true)
+			if c.OPMark != tcell.ColorDefault {
+				warning = warning.Foreground(c.OPMark)
+			}
+			w.SetColors(style, warning)
+		case *TabBar:
+			w.SetColors(tcell.StyleDefault.Foreground(c.Secondary).Background(c.Background),
+				tcell.StyleDefault.Foreground(c.SelectedText).Background(c.Selected))
+		}
+
+		if b, ok := w.(interface {
+			SetBackgroundColor(tcell.Color) *tview.Box
+			SetBorderColor(tcell.Color) *tview.Box
+			SetTitleColor(tcell.Color) *tview.Box
+		}); ok {
+			b.SetBackgroundColor(c.Background)
+			b.SetBorderColor(c.Border)
+			b.SetTitleColor(c.Title)
+		}
+	}
+}
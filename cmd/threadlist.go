@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/2chboarding/boarding/richtext"
+)
+
+// Режимы списка тредов
+const (
+	ListCompact  = "compact"  // одна строка: заголовок и число постов
+	ListDetailed = "detailed" // вторая строка со сведениями о треде и началом ОП-поста
+)
+
+// maxSubjectWidth ширина заголовка треда, взятого из текста ОП-поста
+const maxSubjectWidth = 80
+
+// threadSubject возвращает тему треда, без темы - начало ОП-поста или номер
+func threadSubject(board BoardStruct, thID PostID) string {
+	post := board.Posts[thID]
+
+	if subject := html.UnescapeString(post.Subject); subject != "" {
+		return subject
+	}
+	if text := richtext.Parse(post.Comment).PlainText(); text != "" {
+		return runewidth.Truncate(text, maxSubjectWidth, "…")
+	}
+
+	return fmt.Sprintf("№%v", thID)
+}
+
+// ago описывает, сколько времени прошло
+func ago(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "только что"
+	case d < time.Hour:
+		return fmt.Sprintf("%v мин назад", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%v ч назад", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%v дн назад", int(d.Hours()/24))
+	}
+}
+
+// threadDetails возвращает сведения о треде для подробного списка: число
+// постов и файлов, время создания и последнего ответа, начало ОП-поста
+func threadDetails(board BoardStruct, thID PostID, now time.Time) string {
+	thread := board.Threads[thID]
+	post := board.Posts[thID]
+
+	details := plural(thread.PostsCount, "пост", "поста", "постов")
+	if thread.FilesCount > 0 {
+		details += " · " + plural(thread.FilesCount, "файл", "файла", "файлов")
+	}
+	if post.Timestamp > 0 {
+		details += " · " + time.Unix(post.Timestamp, 0).Format("02.01.06 15:04")
+	}
+	if thread.Lasthit > 0 {
+		details += " · бамп " + ago(now.Sub(time.Unix(thread.Lasthit, 0)))
+	}
+	if text := richtext.Parse(post.Comment).PlainText(); text != "" {
+		details += " · " + text
+	}
+
+	return details
+}
@@ -2,357 +2,400 @@ package main
 
 import (
 	"fmt"
-	"io"
-	"io/ioutil"
-	"os"
-	"strings"
-
-	"golang.org/x/net/html"
+	"html"
 
 	"github.com/gdamore/tcell"
 	"github.com/rivo/tview"
+
+	"github.com/2chboarding/boarding/richtext"
 )
 
-// TextBlockStyle keeps style and other information
-type TextBlockStyle struct {
-	style tcell.Style
-	tag   string
-}
+// Link keeps information about link
+type Link = richtext.Link
 
-// TextBlock keeps text block (word/token) with single style
-type TextBlock struct {
-	text  string
-	width int
+// ThreadView display thread
+type ThreadView struct {
+	*tview.Box
+	text       string
+	doc        *richtext.Document
+	cachedText *richtext.Text
+	layout     richtext.Options // layout options, width is set on drawing
+	spoilers   bool             // show text of spoilers
+	//Post     PostStruct
+	vscroll  int
+	oldWidth int
 
-	style TextBlockStyle
-	ref   int // url index
+	styles      richtext.Styles
+	selLink     int                            // selected link of thread text, 0 if none
+	popups      []*PostView                    // stack of post previews, last one is on top
+	level       int                            // where the link cursor is: 0 - thread, i - popups[i-1]
+	previewFunc func(link Link) (string, bool) // returns text of post referenced by link
+	linkFunc    func(link Link)                // activates selected link
 }
 
-// TextLine keeps single text line with width not more that specified
-type TextLine struct {
-	blocks []TextBlock
-	width  int
+// PostView displays single post, used for popup previews
+type PostView struct {
+	*tview.Box
+	PostStruct                    // original post
+	postID     PostID             //
+	text       string             // HTML of the post
+	postText   *richtext.Text     // Cached post text
+	doc        *richtext.Document // parsed post text
+	selLink    int                // selected link, 0 if none
+	spoilers   bool               // show text of spoilers
+	styles     richtext.Styles
 }
 
-// Text keeps full text splitted by lines and blocks
-type Text struct {
-	width int
-	lines []TextLine
-	links Links
+type ThreadView2 struct {
+	*tview.Box
+	Posts []PostView
 }
 
-func (txt *Text) Dump() {
-	fl, err := os.Create("dump.txt")
+// NewThreadView creates empty ThreadView with default styles
+func NewThreadView() *ThreadView {
+	return &ThreadView{Box: tview.NewBox(), styles: richtext.DefaultStyles}
+}
+
+// SetStyles sets styles of thread text
+func (tv *ThreadView) SetStyles(styles richtext.Styles) *ThreadView {
+	tv.styles = styles
+	return tv
+}
 
-	s := fmt.Sprint(txt)
+// NewPostView creates post preview for the given post text
+func NewPostView(text string) *PostView {
+	pv := &PostView{Box: tview.NewBox(), text: text, doc: richtext.Parse(text)}
+	pv.SetBorder(true)
 
-	ioutil.WriteFile("dump1.txt", []byte(s), 0666)
+	return pv
+}
 
-	if err != nil {
-		panic(err)
+// SetLayout lays out post text again if layout options have been changed
+func (pv *PostView) SetLayout(opts richtext.Options) {
+	if pv.postText != nil && pv.postText.Options == opts {
+		return
 	}
 
-	defer fl.Close()
+	pv.postText = pv.doc.Layout(opts)
+}
 
-	fl.WriteString(fmt.Sprintf("Text width %v\n", txt.width))
+func (pv *PostView) Draw(screen tcell.Screen) {
+	pv.Box.Draw(screen)
 
-	for i, l := range txt.lines {
-		fl.WriteString(
-			fmt.Sprintf(" Line of text # %v, has %v blocks and width %v\n", i, len(l.blocks), l.width))
+	x, y, w, h := pv.Box.GetInnerRect()
+	pv.postText.Draw(screen, x, y, w, h, 0, richtext.DrawOptions{
+		Styles:   pv.styles,
+		Selected: pv.selLink,
+		Spoilers: pv.spoilers,
+	})
+}
 
-		for j, b := range l.blocks {
-			fl.WriteString(
-				fmt.Sprintf("  Block %v has width %v and style %x at %v. Text \"%v\"\n", j, b.width, b.style.style, b.style.tag, b.text))
-		}
+// SetText set new text to ThreadVew
+func (tv *ThreadView) SetText(text string) {
+	tv.text = text
+	tv.doc = richtext.Parse(text)
+	tv.UpdateCache()
+
+	// keep link cursor on rerender of the same thread
+	if _, ok := tv.cachedText.Links[tv.selLink]; !ok {
+		tv.selLink = 0
 	}
 
-	//ioutil.WriteFile("dump1.txt", txt.(string), 0666)
-}
+	Debugf("thread text laid out to %v lines with %v links at width %v",
+		len(tv.cachedText.Lines), len(tv.cachedText.Links), tv.cachedText.Width)
 
-// Link keeps information about link
-type Link struct {
-	text   string
-	url    string
-	local  bool
-	thread PostID
-	post   PostID
+	//tv.tlines = parseText2(text, w)
 }
 
-// Links map of links
-type Links map[int]Link
-
-// ThreadView display thread
-type ThreadView struct {
-	*tview.Box
-	text       string
-	cachedText Text
-	//Post     PostStruct
-	vscroll  int
-	oldWidth int
+// ScrollToBeginning scroll ThreadView to first line
+func (tv *ThreadView) ScrollToBeginning() {
+	tv.vscroll = 0
+	tv.selLink = 0
 }
 
-type PostView struct {
-	tview.Box
-	PostStruct        // original post
-	postID     PostID //
-	postText   Text   // Cached post text
+// Scroll returns number of the first visible line
+func (tv *ThreadView) Scroll() int {
+	return tv.vscroll
 }
 
-type ThreadView2 struct {
-	*tview.Box
-	Posts []PostView
+// SetScroll scrolls ThreadView to the line
+func (tv *ThreadView) SetScroll(line int) {
+	tv.closePopups()
+	tv.scrollTo(line)
+	tv.selLink = 0
 }
 
-func (pv *PostView) Draw(screen tcell.Screen) {
-	x, y, w, h := pv.Box.GetInnerRect()
+// ScrollToAnchor scrolls ThreadView to the line of anchor name, returns
+// false if there is no such anchor
+func (tv *ThreadView) ScrollToAnchor(name string) bool {
+	if tv.cachedText == nil {
+		return false
+	}
 
-	// if pv.oldWidth != w {
-	// 	// reparse text
-	// 	tv.UpdateCache(tv.text)
-	// 	tv.oldWidth = w
-	// }
+	line := tv.cachedText.AnchorLine(name)
+	if line < 0 {
+		return false
+	}
 
-	//	pv.Box.Draw(screen)
+	tv.closePopups()
+	tv.vscroll = line
+	return true
+}
 
-	for yy := 0; yy < h; yy++ {
-		lnum := yy
-		var tl *TextLine
+// CurrentAnchor returns the nearest anchor accepted by match placed above
+// the selected link, or above the first visible line if no link is selected
+// on the screen. Empty string is returned if there is no such anchor
+func (tv *ThreadView) CurrentAnchor(match func(name string) bool) string {
+	if tv.cachedText == nil {
+		return ""
+	}
 
-		if lnum >= 0 && lnum < len(pv.postText.lines) {
-			tl = &pv.postText.lines[lnum]
+	line := tv.vscroll
+	if tv.selLink != 0 {
+		_, _, _, h := tv.GetInnerRect()
+		if sel := tv.cachedText.LinkLine(tv.selLink); sel >= tv.vscroll && sel < tv.vscroll+h {
+			line = sel
 		}
+	}
+	if line >= len(tv.cachedText.Lines) {
+		line = len(tv.cachedText.Lines) - 1
+	}
 
-		xx := 0
-
-		// output text
-		if tl != nil {
-			for _, b := range tl.blocks {
-				for _, ch := range b.text {
-
-					if xx < w {
-						if xx > w {
-							panic("TextLine exceed widget width")
-						}
-						screen.SetContent(x+xx, y+yy, ch, nil, b.style.style)
-						xx++
-					}
-				}
+	for ; line >= 0; line-- {
+		anchors := tv.cachedText.Lines[line].Anchors
+		for i := len(anchors) - 1; i >= 0; i-- {
+			if match(anchors[i]) {
+				return anchors[i]
 			}
 		}
-
-		// fill left space
-		for lx := xx; lx < w; lx++ {
-			screen.SetContent(x+lx, y+yy, ' ', nil, tcell.StyleDefault)
-		}
 	}
 
+	return ""
 }
 
-// SetText set new text to ThreadVew
-func (tv *ThreadView) SetText(text string) {
-	_, _, w, _ := tv.Box.GetInnerRect()
-	tv.text = text
-	tv.cachedText.width = w
-	tv.UpdateCache(text)
+// HasPopups reports whether any post preview is open
+func (tv *ThreadView) HasPopups() bool {
+	return len(tv.popups) > 0
+}
 
-	tv.cachedText.Dump()
+// ToggleSpoilers shows or hides text of spoilers
+func (tv *ThreadView) ToggleSpoilers() {
+	tv.spoilers = !tv.spoilers
+}
 
-	//tv.tlines = parseText2(text, w)
+// ToggleHyphenation switches hyphenation of wrapped words
+func (tv *ThreadView) ToggleHyphenation() {
+	tv.layout.Hyphenate = !tv.layout.Hyphenate
+	tv.UpdateCache()
 }
 
-// ScrollToBeginning scroll ThreadView to first line
-func (tv *ThreadView) ScrollToBeginning() {
-	tv.vscroll = 0
+// ToggleJustify switches stretching of wrapped lines to full width
+func (tv *ThreadView) ToggleJustify() {
+	tv.layout.Justify = !tv.layout.Justify
+	tv.UpdateCache()
 }
 
-type tagAttr struct {
-	attrName  string
-	attrValue string
+// SetPreviewFunc sets handler returning text of the post referenced by link,
+// it is called when link cursor moves to a post link
+func (tv *ThreadView) SetPreviewFunc(handler func(link Link) (string, bool)) *ThreadView {
+	tv.previewFunc = handler
+	return tv
 }
 
-// ParseHTML Parse HTML and emit tokens
-func ParseHTML(source string, eventFunc func(tt html.TokenType, token string, attrs []tagAttr)) {
-	tokenizer := html.NewTokenizer(strings.NewReader(source))
+// SetLinkFunc sets handler called when selected link of thread text is activated
+func (tv *ThreadView) SetLinkFunc(handler func(link Link)) *ThreadView {
+	tv.linkFunc = handler
+	return tv
+}
 
-	for {
-		tokenType := tokenizer.Next()
+// levelText returns text and selected link of the given cursor level
+func (tv *ThreadView) levelText(level int) (*richtext.Text, *int) {
+	if level == 0 {
+		return tv.cachedText, &tv.selLink
+	}
 
-		switch tokenType {
-		case html.ErrorToken:
-			if tokenizer.Err() == io.EOF {
-				return
-			}
-			// unknown error
-			panic(tokenizer.Err())
-
-		case html.StartTagToken, html.EndTagToken:
-			tagName, hasAttrs := tokenizer.TagName()
-			tagNameStr := string(tagName)
-
-			if tokenType == html.StartTagToken {
-				var attrs []tagAttr
-
-				if hasAttrs {
-					for {
-						key, value, more := tokenizer.TagAttr()
-
-						attrs = append(attrs, tagAttr{string(key), string(value)})
-						if !more {
-							break
-						}
-					}
-				}
-
-				eventFunc(tokenType, tagNameStr, attrs)
-
-			} else {
-				// html.EndTagToken
-				if hasAttrs {
-					panic("EndTag has attributes")
-				}
-				//emitCloseTag(tagNameStr)
-				eventFunc(tokenType, tagNameStr, nil)
-			}
+	pv := tv.popups[level-1]
+	return pv.postText, &pv.selLink
+}
+
+// moveLink moves link cursor of the current level by delta links and
+// opens preview of the newly selected post link
+func (tv *ThreadView) moveLink(delta int) {
+	txt, sel := tv.levelText(tv.level)
+	next := *sel + delta
 
-		case html.TextToken:
-			eventFunc(tokenType, string(tokenizer.Text()), nil)
+	if tv.level == 0 {
+		// start from visible links if thread was scrolled away from selection
+		_, _, _, h := tv.GetInnerRect()
+		if line := txt.LinkLine(*sel); *sel == 0 || line < tv.vscroll || line >= tv.vscroll+h {
+			next = tv.firstVisibleLink(h, delta)
 		}
 	}
+
+	if _, ok := txt.Links[next]; !ok {
+		return
+	}
+
+	tv.selectLink(next)
 }
 
-// NewTextParser parse HTML from source to Text
-func (txt *Text) NewTextParser(source string) {
-	var currLine TextLine
-	var currBlock TextBlock
+// selectLink moves link cursor of the current level to link ref, scrolls
+// thread to it and opens preview of the selected post link
+func (tv *ThreadView) selectLink(ref int) {
+	txt, sel := tv.levelText(tv.level)
+	*sel = ref
 
-	var style []TextBlockStyle
+	tv.popups = tv.popups[:tv.level]
 
-	currStyle := func() TextBlockStyle {
-		return style[len(style)-1]
+	if tv.level == 0 {
+		_, _, _, h := tv.GetInnerRect()
+		if line := txt.LinkLine(ref); line < tv.vscroll {
+			tv.vscroll = line
+		} else if line >= tv.vscroll+h {
+			tv.vscroll = line - h + 1
+		}
 	}
 
-	pushStyle := func(st TextBlockStyle) {
-		style = append(style, st)
-		//currBlock.style = st
+	link := txt.Links[ref]
+	if !link.Local || link.Post == 0 || tv.previewFunc == nil {
+		return
 	}
 
-	popStyle := func() {
-		if len(style) == 0 {
-			panic("Style stack empty")
-		}
-		style = style[:len(style)-1]
-		//currBlock.style = currStyle()
+	if text, ok := tv.previewFunc(link); ok {
+		tv.popups = append(tv.popups, NewPostView(text))
 	}
+}
 
-	pushStyle(TextBlockStyle{tcell.StyleDefault, ""})
-
-	flushTextLine := func() {
-		txt.lines = append(txt.lines, currLine)
-		currLine.blocks = nil
-		currLine.width = 0
+// UpdatePreviews renders opened post previews again, e.g. when posts they
+// show are loaded. Previews opened from changed ones are closed
+func (tv *ThreadView) UpdatePreviews() {
+	if tv.previewFunc == nil {
+		return
 	}
 
-	flushTextBlock := func() {
-		if currLine.width+currBlock.width > txt.width {
-			flushTextLine()
+	for i, pv := range tv.popups {
+		txt, sel := tv.levelText(i)
+		if txt == nil {
+			return
 		}
-
-		if currBlock.text != "" || currBlock.width != 0 {
-			// append current text block to current text line
-			currLine.blocks = append(currLine.blocks, currBlock)
-			currLine.width += currBlock.width
+		text, ok := tv.previewFunc(txt.Links[*sel])
+		if ok && text == pv.text {
+			continue
 		}
 
-		// clear current text block
-		currBlock.text = ""
-		currBlock.width = 0
-		currBlock.style = currStyle()
+		tv.popups = tv.popups[:i]
+		if ok {
+			tv.popups = append(tv.popups, NewPostView(text))
+		}
+		if tv.level > len(tv.popups) {
+			tv.level = len(tv.popups)
+		}
+		return
 	}
+}
 
-	wrapText := func(text string) {
-		var inText bool
-
-		for _, ch := range text {
-			switch ch {
-			case ' ':
-				if inText {
-					flushTextBlock()
-				}
-				inText = false
-
-			default:
-				if !inText {
-					flushTextBlock()
-				}
-				inText = true
-			}
+// firstVisibleLink returns first (delta > 0) or last (delta < 0) link on the
+// screen of thread text with height h
+func (tv *ThreadView) firstVisibleLink(h, delta int) int {
+	result := 0
 
-			currBlock.text += string(ch)
-			currBlock.width++
+	for ref := 1; ref <= len(tv.cachedText.Links); ref++ {
+		line := tv.cachedText.LinkLine(ref)
+		if line < tv.vscroll || line >= tv.vscroll+h {
+			continue
 		}
 
-		flushTextBlock()
+		result = ref
+		if delta > 0 {
+			break
+		}
 	}
 
-	eventFunc := func(tt html.TokenType, token string, attrs []tagAttr) {
-		switch tt {
-		case html.StartTagToken:
-			switch token {
-			case "br":
-				flushTextBlock()
-				flushTextLine()
-
-			case "a":
-				cs := currStyle()
-				cs.tag = "a"
-				cs.style = tcell.StyleDefault.Foreground(tcell.ColorLime)
-				pushStyle(cs)
-				flushTextBlock()
+	return result
+}
 
-			case "strong":
-				cs := currStyle()
-				cs.tag = "strong"
-				cs.style = cs.style.Bold(true)
-				pushStyle(cs)
-				flushTextBlock()
+// closePopup closes topmost post preview
+func (tv *ThreadView) closePopup() {
+	if len(tv.popups) == 0 {
+		return
+	}
 
-			default:
-				flushTextBlock()
-			}
+	tv.popups = tv.popups[:len(tv.popups)-1]
+	if tv.level > len(tv.popups) {
+		tv.level = len(tv.popups)
+	}
+}
 
-		case html.EndTagToken:
-			switch token {
-			case "a":
-				popStyle()
-				flushTextBlock()
+// closePopups closes all post previews
+func (tv *ThreadView) closePopups() {
+	tv.popups = nil
+	tv.level = 0
+}
 
-			case "strong":
-				popStyle()
-				flushTextBlock()
+// anchorRow returns screen row of the link selected on the given cursor level
+func (tv *ThreadView) anchorRow(level int) int {
+	if level == 0 {
+		_, y, _, _ := tv.GetInnerRect()
+		return y + tv.cachedText.LinkLine(tv.selLink) - tv.vscroll
+	}
 
-			default:
-				flushTextBlock()
-			}
+	pv := tv.popups[level-1]
+	_, y, _, _ := pv.GetInnerRect()
+	return y + pv.postText.LinkLine(pv.selLink)
+}
 
-		case html.TextToken:
-			wrapText(token)
+// drawPopups draws post previews under or over the links they were opened from
+func (tv *ThreadView) drawPopups(screen tcell.Screen) {
+	x, y, w, h := tv.GetInnerRect()
 
+	for i, pv := range tv.popups {
+		// nested previews are shifted right
+		px := x + 2*(i+1)
+		pw := w - 2*(i+1) - 2
+		if pw > 80 {
+			pw = 80
+		}
+		if pw < 10 {
+			return
+		}
+		opts := tv.layout
+		opts.Width = pw - 2
+		pv.SetLayout(opts)
+		pv.spoilers = tv.spoilers
+		pv.styles = tv.styles
+
+		anchor := tv.anchorRow(i)
+		ph := len(pv.postText.Lines) + 2
+		below := y + h - anchor - 1
+		above := anchor - y
+
+		var py int
+		switch {
+		case ph <= below:
+			py = anchor + 1
+		case ph <= above:
+			py = anchor - ph
+		case below >= above:
+			py, ph = anchor+1, below
 		default:
-			panic(fmt.Sprintf("Unknown TokenType %v", tt))
+			py, ph = y, above
 		}
-	}
-
-	ParseHTML(source, eventFunc)
-
-	// flush last text remains
-	flushTextBlock()
-	flushTextLine()
 
+		pv.SetRect(px, py, pw, ph)
+		pv.Draw(screen)
+	}
 }
 
-func (tv *ThreadView) UpdateCache(source string) {
-	tv.cachedText.lines = nil
-	tv.cachedText.NewTextParser(tv.text)
+// UpdateCache lays out thread text to the current width
+func (tv *ThreadView) UpdateCache() {
+	if tv.doc == nil {
+		tv.doc = richtext.Parse(tv.text)
+	}
+
+	_, _, w, _ := tv.GetInnerRect()
+	tv.layout.Width = w
+	tv.cachedText = tv.doc.Layout(tv.layout)
+	tv.closePopups()
 }
 
 func (tv *ThreadView) SetPost(post *PostStruct) {
@@ -363,44 +406,18 @@ func (tv *ThreadView) SetPost(post *PostStruct) {
 func (tv *ThreadView) Draw(screen tcell.Screen) {
 	x, y, w, h := tv.Box.GetInnerRect()
 
-	if tv.oldWidth != w {
-		// reparse text
-		tv.UpdateCache(tv.text)
+	if tv.oldWidth != w || tv.cachedText == nil {
+		// layout text again
+		tv.UpdateCache()
 		tv.oldWidth = w
 	}
 
-	//
-	for yy := 0; yy < h; yy++ {
-		lnum := tv.vscroll + yy
-		var tl *TextLine
-
-		if lnum >= 0 && lnum < len(tv.cachedText.lines) {
-			tl = &tv.cachedText.lines[lnum]
-		}
-
-		xx := 0
-
-		// output text
-		if tl != nil {
-			for _, b := range tl.blocks {
-				for _, ch := range b.text {
-
-					if xx < w {
-						if xx > w {
-							panic("TextLine exceed widget width")
-						}
-						screen.SetContent(x+xx, y+yy, ch, nil, b.style.style)
-						xx++
-					}
-				}
-			}
-		}
-
-		// fill left space
-		for lx := xx; lx < w; lx++ {
-			screen.SetContent(x+lx, y+yy, ' ', nil, tcell.StyleDefault)
-		}
-	}
+	tv.cachedText.Draw(screen, x, y, w, h, tv.vscroll, richtext.DrawOptions{
+		Styles:   tv.styles,
+		Selected: tv.selLink,
+		Spoilers: tv.spoilers,
+	})
+	tv.drawPopups(screen)
 
 	/*pv := &PostView{Box: tview.NewBox()}
 	pv.SetRect(x+5, y+5, w-10, h-10)
@@ -420,27 +437,390 @@ func (tv *ThreadView) InputHandler() func(event *tcell.EventKey, setFocus func(p
 
 		switch key := event.Key(); key {
 		case tcell.KeyDown:
-			tv.vscroll++
-		case tcell.KeyBacktab, tcell.KeyUp, tcell.KeyLeft:
-			tv.vscroll--
+			tv.closePopups()
+			tv.scrollBy(1)
+		case tcell.KeyUp, tcell.KeyLeft:
+			tv.closePopups()
+			tv.scrollBy(-1)
 		case tcell.KeyPgDn:
-			tv.vscroll += h
+			tv.closePopups()
+			tv.scrollBy(h)
 		case tcell.KeyPgUp:
-			tv.vscroll -= h
+			tv.closePopups()
+			tv.scrollBy(-h)
+		case tcell.KeyHome:
+			tv.closePopups()
+			tv.scrollTo(0)
+		case tcell.KeyEnd:
+			tv.closePopups()
+			tv.scrollTo(len(tv.cachedText.Lines))
+
+		case tcell.KeyTab:
+			tv.moveLink(1)
+		case tcell.KeyBacktab:
+			tv.moveLink(-1)
+		case tcell.KeyEnter:
+			// move link cursor into the topmost preview
+			if tv.level < len(tv.popups) {
+				tv.level = len(tv.popups)
+			} else if link, ok := tv.cachedText.Links[tv.selLink]; ok && tv.level == 0 && tv.linkFunc != nil {
+				tv.linkFunc(link)
+			}
+		case tcell.KeyEsc:
+			tv.closePopup()
+		}
+	})
+}
+
+// wheelLines is number of lines scrolled by mouse wheel step
+const wheelLines = 3
+
+// scrollTo scrolls text to the line, the last line stays at the bottom
+// like with End
+func (tv *ThreadView) scrollTo(line int) {
+	_, _, _, h := tv.GetInnerRect()
+
+	last := 0
+	if tv.cachedText != nil {
+		last = len(tv.cachedText.Lines) - h
+	}
+
+	tv.vscroll = line
+	if tv.vscroll > last {
+		tv.vscroll = last
+	}
+	if tv.vscroll < 0 {
+		tv.vscroll = 0
+	}
+}
+
+// scrollBy scrolls text by n lines
+func (tv *ThreadView) scrollBy(n int) {
+	tv.scrollTo(tv.vscroll + n)
+}
+
+// MouseHandler handles clicks on links and scrolling by mouse wheel
+func (tv *ThreadView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return tv.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		x, y := event.Position()
+		if !tv.InRect(x, y) {
+			return false, nil
+		}
 
+		switch action {
+		case tview.MouseLeftClick:
+			setFocus(tv)
+			tv.click(x, y)
+		case tview.MouseScrollUp:
+			tv.closePopups()
+			tv.scrollBy(-wheelLines)
+		case tview.MouseScrollDown:
+			tv.closePopups()
+			tv.scrollBy(wheelLines)
+		default:
+			return false, nil
 		}
+
+		return true, nil
 	})
 }
 
-// RenderThread join all posts text to one big
-func (ib *ImageBoard) RenderThread(boardID string, threadID PostID) string {
+// click selects link at screen position, link in a preview is selected like
+// with Tab, link of thread text is also activated like with Enter
+func (tv *ThreadView) click(x, y int) {
+	if tv.cachedText == nil {
+		return
+	}
+
+	// previews are checked from the topmost one
+	for i := len(tv.popups) - 1; i >= 0; i-- {
+		pv := tv.popups[i]
+		if !pv.InRect(x, y) {
+			continue
+		}
+
+		px, py, _, _ := pv.GetInnerRect()
+		if ref := pv.postText.LinkAt(y-py, x-px); ref != 0 {
+			tv.level = i + 1
+			tv.selectLink(ref)
+		}
+		return
+	}
+
+	tv.closePopups()
+
+	tx, ty, _, _ := tv.GetInnerRect()
+	ref := tv.cachedText.LinkAt(tv.vscroll+y-ty, x-tx)
+	if ref == 0 {
+		return
+	}
+
+	tv.selectLink(ref)
+	if tv.linkFunc != nil {
+		tv.linkFunc(tv.cachedText.Links[ref])
+	}
+}
+
+// RenderThread join all posts text to one big, unread mark is placed
+// before post unreadFrom. Posts hidden by filter are counted at the top of
+// thread, collapsed ones are replaced with a placeholder
+func (ib *ImageBoard) RenderThread(boardID string, threadID, unreadFrom PostID, filter func(PostID) FilterAction) string {
 
 	var result string
+	hidden := 0
 	for _, postID := range ib.Boards[boardID].Threads[threadID].Posts {
-		post := ib.Boards[boardID].Posts[postID]
-		result += post.Name + "<br><br>"
-		result += post.Comment + "<br><br>"
+		if postID == unreadFrom {
+			result += unreadMark
+		}
+
+		switch filter(postID) {
+		case FilterHide:
+			hidden++
+		case FilterCollapse:
+			result += renderFilteredPost(postID) + "<br>"
+		default:
+			result += ib.RenderPost(boardID, threadID, postID) + "<br>"
+		}
+	}
+
+	if hidden > 0 {
+		result = fmt.Sprintf("<strong>Скрыто постов: %v</strong><br><br>", hidden) + result
+	}
+
+	return result
+}
+
+// renderFilteredPost renders placeholder of post collapsed by filter, the
+// placeholder link reveals the post
+func renderFilteredPost(postID PostID) string {
+	return fmt.Sprintf(`<a name="%v"></a><span class="post-header">№%v</span> <a href="%v" data-num="%v">[скрыт]</a><br>`,
+		postID, postID, revealLinkURL, postID)
+}
+
+// ThreadViewMode is a way of arranging thread posts
+type ThreadViewMode int
+
+// Thread view modes
+const (
+	// ModeChrono shows posts in chronological order
+	ModeChrono ThreadViewMode = iota
+	// ModeTree shows posts as a tree by reply links
+	ModeTree
+)
+
+// ThreadViewState keeps view settings of single thread
+type ThreadViewState struct {
+	Mode       ThreadViewMode
+	Collapsed  map[PostID]bool // posts with hidden subtrees in ModeTree
+	Watched    bool            // thread is refreshed periodically
+	LastSeen   PostID          // newest post shown to user
+	UnreadFrom PostID          // first post that was new when thread was opened, 0 if none
+	ShowHidden bool            // posts hidden by filters are shown
+	Revealed   map[PostID]bool // posts shown despite filters
+}
+
+// MarkRead remembers posts of opened thread as seen, posts newer than
+// previously seen ones are marked as unread in rendered thread
+func (s *ThreadViewState) MarkRead(posts ThreadPosts) {
+	s.UnreadFrom = 0
+	for _, postID := range posts {
+		if s.LastSeen != 0 && postID > s.LastSeen {
+			s.UnreadFrom = postID
+			break
+		}
+	}
+
+	if n := len(posts); n > 0 && posts[n-1] > s.LastSeen {
+		s.LastSeen = posts[n-1]
+	}
+}
+
+// Unread returns number of posts newer than the seen ones
+func (s *ThreadViewState) Unread(posts ThreadPosts) int {
+	if s.LastSeen == 0 {
+		return 0
+	}
+
+	count := 0
+	for _, postID := range posts {
+		if postID > s.LastSeen {
+			count++
+		}
 	}
 
+	return count
+}
+
+// unreadAnchor is name of anchor before first unread post
+const unreadAnchor = "unread"
+
+// unreadMark separates read posts from unread ones
+const unreadMark = `<a name="` + unreadAnchor + `"></a><span class="unread-mark">——— новые посты ———</span><br><br>`
+
+// collapseLinkURL is url of links toggling post subtree
+const collapseLinkURL = "#collapse"
+
+// revealLinkURL is url of links showing post hidden by filter
+const revealLinkURL = "#reveal"
+
+// PostFilter decides whether post of board is hidden or collapsed
+type PostFilter func(boardID string, postID PostID) FilterAction
+
+// RenderThreadMode renders thread according to view state, posts are hidden
+// by filter unless they are revealed, filter may be nil
+func (ib *ImageBoard) RenderThreadMode(boardID string, threadID PostID, state *ThreadViewState, filter PostFilter) string {
+	visible := func(postID PostID) FilterAction {
+		if filter == nil || state.ShowHidden || state.Revealed[postID] {
+			return FilterNone
+		}
+		return filter(boardID, postID)
+	}
+
+	if state.Mode == ModeTree {
+		return ib.RenderThreadTree(boardID, threadID, state.Collapsed, state.UnreadFrom, visible)
+	}
+
+	return ib.RenderThread(boardID, threadID, state.UnreadFrom, visible)
+}
+
+// RenderThreadTree renders posts as a tree, post is placed under the first
+// earlier post of the thread it replies to. Subtrees of collapsed posts are
+// hidden. Posts hidden by filter are collapsed to keep their replies in place
+func (ib *ImageBoard) RenderThreadTree(boardID string, threadID PostID, collapsed map[PostID]bool, unreadFrom PostID, filter func(PostID) FilterAction) string {
+	board := ib.Boards[boardID]
+	posts := board.Threads[threadID].Posts
+
+	var roots ThreadPosts
+	children := make(map[PostID]ThreadPosts)
+	inThread := make(map[PostID]bool, len(posts))
+
+	for _, postID := range posts {
+		var parent PostID
+		for _, target := range ReplyLinks(boardID, board.Posts[postID].Comment) {
+			if inThread[target] {
+				parent = target
+				break
+			}
+		}
+		inThread[postID] = true
+
+		if parent == 0 {
+			roots = append(roots, postID)
+		} else {
+			children[parent] = append(children[parent], postID)
+		}
+	}
+
+	var subtreeSize func(postID PostID) int
+	subtreeSize = func(postID PostID) int {
+		size := len(children[postID])
+		for _, c := range children[postID] {
+			size += subtreeSize(c)
+		}
+		return size
+	}
+
+	var result string
+	var render func(postID PostID)
+	render = func(postID PostID) {
+		if postID == unreadFrom {
+			result += unreadMark
+		}
+
+		kids := children[postID]
+		if len(kids) > 0 {
+			sign := "[-]"
+			if collapsed[postID] {
+				sign = "[+]"
+			}
+			result += fmt.Sprintf(`<a href="%v" data-num="%v">%v</a> `, collapseLinkURL, postID, sign)
+		}
+		if filter(postID) != FilterNone {
+			result += renderFilteredPost(postID)
+		} else {
+			result += ib.RenderPost(boardID, threadID, postID)
+		}
+
+		if len(kids) == 0 {
+			result += "<br>"
+			return
+		}
+
+		if collapsed[postID] {
+			result += fmt.Sprintf("<strong>Скрыто ответов: %v</strong><br><br>", subtreeSize(postID))
+			return
+		}
+
+		result += "<br><blockquote>"
+		for _, c := range kids {
+			render(c)
+		}
+		result += "</blockquote>"
+	}
+
+	for _, postID := range roots {
+		render(postID)
+	}
+
+	return result
+}
+
+// RenderPost renders header and comment of single post, the post starts with
+// anchor named by its number
+func (ib *ImageBoard) RenderPost(boardID string, threadID, postID PostID) string {
+	board := ib.Boards[boardID]
+	post := board.Posts[postID]
+
+	result := fmt.Sprintf(`<a name="%v"></a>`, postID)
+	result += `<span class="post-header">` + post.Name + `</span>`
+	if post.OP || postID == threadID {
+		result += ` <span class="post-op">#OP</span>`
+	}
+	result += "<br>"
+	if replies := board.Replies[postID]; len(replies) > 0 {
+		result += renderReplies(boardID, threadID, replies) + "<br>"
+	}
+	result += "<br>"
+	result += post.Comment + "<br>"
+
 	return result
 }
+
+// linkedPost returns board and number of the post referenced by link
+func linkedPost(boardID string, link Link) (string, PostID) {
+	if link.Board != "" {
+		boardID = link.Board
+	}
+	return boardID, PostID(link.Post)
+}
+
+// RenderLinkedPost renders post referenced by link if the post is loaded
+func (ib *ImageBoard) RenderLinkedPost(boardID string, link Link) (string, bool) {
+	boardID, postID := linkedPost(boardID, link)
+	if _, ok := ib.Boards[boardID].Posts[postID]; !ok {
+		return "", false
+	}
+
+	return ib.RenderPost(boardID, PostID(link.Thread), postID), true
+}
+
+// MissingLinkedThread returns board and thread of the post referenced by
+// link if the post is not loaded yet and may be loaded with its thread
+func (ib *ImageBoard) MissingLinkedThread(boardID string, link Link) (string, PostID, bool) {
+	boardID, postID := linkedPost(boardID, link)
+	board, ok := ib.Boards[boardID]
+	if !ok || link.Thread == 0 {
+		return "", 0, false
+	}
+	if _, ok := board.Posts[postID]; ok {
+		return "", 0, false
+	}
+
+	return boardID, PostID(link.Thread), true
+}
+
+// RenderPreviewMessage renders message shown in preview in place of post
+// postID, like loading status or load error
+func RenderPreviewMessage(postID PostID, message string) string {
+	return fmt.Sprintf("<strong>&gt;&gt;%v</strong><br>%v", postID, html.EscapeString(message))
+}
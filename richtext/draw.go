@@ -0,0 +1,201 @@
+package richtext
+
+import (
+	"github.com/gdamore/tcell"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+// Styles maps text attributes to terminal styles. Styles except Text are
+// overlaid on the style of surrounding text: their default colors keep
+// colors of the text and their attributes are added to text attributes
+type Styles struct {
+	Text      tcell.Style
+	Link      tcell.Style
+	Greentext tcell.Style
+	Spoiler   tcell.Style
+	Header    tcell.Style
+	OPMark    tcell.Style
+	Unread    tcell.Style
+	Guide     tcell.Style // tree guides in indentation of nested blocks
+}
+
+// DefaultStyles are styles used by default
+var DefaultStyles = Styles{
+	Text:      tcell.StyleDefault,
+	Link:      tcell.StyleDefault.Foreground(tcell.ColorLime),
+	Greentext: tcell.StyleDefault.Foreground(tcell.NewHexColor(0x789922)),
+	Spoiler:   tcell.StyleDefault.Background(tcell.ColorGray),
+	Header:    tcell.StyleDefault.Bold(true),
+	OPMark:    tcell.StyleDefault.Foreground(tcell.ColorRed),
+	Unread:    tcell.StyleDefault.Foreground(tcell.ColorYellow),
+	Guide:     tcell.StyleDefault.Dim(true),
+}
+
+// DrawOptions control drawing of text
+type DrawOptions struct {
+	Styles   Styles
+	Selected int  // link drawn reversed, 0 if none
+	Spoilers bool // draw text of spoilers
+}
+
+// Combining characters used for decorations missing in terminal attributes
+const (
+	strikethroughRune = '\u0336'
+	overlineRune      = '\u0305'
+)
+
+// style returns terminal style and combining runes of run style
+func (s Styles) style(st Style) (tcell.Style, []rune) {
+	style := s.Text
+	for _, layer := range []struct {
+		on    bool
+		style tcell.Style
+	}{
+		{st.Attrs&AttrHeader != 0, s.Header},
+		{st.Attrs&AttrOPMark != 0, s.OPMark},
+		{st.Attrs&AttrUnread != 0, s.Unread},
+		{st.Attrs&AttrGreentext != 0, s.Greentext},
+		{st.Link != 0, s.Link},
+		{st.Attrs&AttrSpoiler != 0, s.Spoiler},
+	} {
+		if layer.on {
+			style = Overlay(style, layer.style)
+		}
+	}
+
+	if st.Attrs&AttrBold != 0 {
+		style = style.Bold(true)
+	}
+	if st.Attrs&AttrItalic != 0 {
+		style = style.Italic(true)
+	}
+	if st.Attrs&AttrUnderline != 0 {
+		style = style.Underline(true)
+	}
+
+	var combc []rune
+	if st.Attrs&AttrStrikethrough != 0 {
+		combc = append(combc, strikethroughRune)
+	}
+	if st.Attrs&AttrOverline != 0 {
+		combc = append(combc, overlineRune)
+	}
+
+	return style, combc
+}
+
+// Overlay returns base style with colors of over that are not default and
+// with attributes of both styles
+func Overlay(base, over tcell.Style) tcell.Style {
+	fg, bg, attrs := over.Decompose()
+	if fg != tcell.ColorDefault {
+		base = base.Foreground(fg)
+	}
+	if bg != tcell.ColorDefault {
+		base = base.Background(bg)
+	}
+
+	if attrs&tcell.AttrBold != 0 {
+		base = base.Bold(true)
+	}
+	if attrs&tcell.AttrBlink != 0 {
+		base = base.Blink(true)
+	}
+	if attrs&tcell.AttrDim != 0 {
+		base = base.Dim(true)
+	}
+	if attrs&tcell.AttrItalic != 0 {
+		base = base.Italic(true)
+	}
+	if attrs&tcell.AttrReverse != 0 {
+		base = base.Reverse(true)
+	}
+	if attrs&tcell.AttrUnderline != 0 {
+		base = base.Underline(true)
+	}
+
+	return base
+}
+
+// ClusterWidth returns number of screen cells taken by grapheme cluster
+// according to East Asian width of its base rune
+func ClusterWidth(runes []rune) int {
+	width := 0
+	for _, r := range runes {
+		if width == 0 {
+			width = runewidth.RuneWidth(r)
+		} else if r == '\ufe0f' {
+			// emoji presentation selector makes narrow symbol wide
+			width = 2
+		}
+	}
+
+	return width
+}
+
+// Draw draws lines of text starting from line first into the given screen
+// rectangle
+func (txt *Text) Draw(screen tcell.Screen, x, y, w, h, first int, opts DrawOptions) {
+	for yy := 0; yy < h; yy++ {
+		lnum := first + yy
+		var tl *Line
+
+		if lnum >= 0 && lnum < len(txt.Lines) {
+			tl = &txt.Lines[lnum]
+		}
+
+		xx := 0
+
+		// output text
+		if tl != nil {
+			// indentation of nested blocks is drawn with tree guides
+			for ; xx < tl.Indent && xx < w; xx++ {
+				ch := ' '
+				if xx%indentStep == 0 {
+					ch = '│'
+				}
+				screen.SetContent(x+xx, y+yy, ch, nil, opts.Styles.Guide)
+			}
+
+			for _, r := range tl.Runs {
+				style, decorations := opts.Styles.style(r.Style)
+				if opts.Selected != 0 && r.Style.Link == opts.Selected {
+					style = style.Reverse(true)
+				}
+				hidden := r.Style.Attrs&AttrSpoiler != 0 && !opts.Spoilers
+
+				// every grapheme cluster is drawn as main rune with combining runes,
+				// wide clusters take two cells
+				gr := uniseg.NewGraphemes(r.Text)
+				for gr.Next() {
+					runes := gr.Runes()
+					cw := ClusterWidth(runes)
+					if cw == 0 {
+						continue
+					}
+					if xx+cw > w {
+						break
+					}
+
+					if hidden {
+						for i := 0; i < cw; i++ {
+							screen.SetContent(x+xx+i, y+yy, ' ', nil, style)
+						}
+						xx += cw
+						continue
+					}
+
+					combc := append(runes[1:len(runes):len(runes)], decorations...)
+					screen.SetContent(x+xx, y+yy, runes[0], combc, style)
+					xx += cw
+				}
+			}
+		}
+
+		// fill left space
+		for lx := xx; lx < w; lx++ {
+			screen.SetContent(x+lx, y+yy, ' ', nil, opts.Styles.Text)
+		}
+	}
+}
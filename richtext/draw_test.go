@@ -0,0 +1,108 @@
+package richtext
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell"
+)
+
+func TestClusterWidth(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster string
+		want    int
+	}{
+		{"latin", "a", 1},
+		{"cyrillic", "ж", 1},
+		{"cjk", "中", 2},
+		{"hangul", "한", 2},
+		{"combining acute", "e\u0301", 1},
+		{"combining marks only", "\u0301", 0},
+		{"emoji", "\U0001f44d", 2},
+		{"emoji with skin tone", "\U0001f44d\U0001f3fd", 2},
+		{"zwj family", "\U0001f468\u200d\U0001f469\u200d\U0001f467", 2},
+		{"narrow heart", "\u2764", 1},
+		{"heart with vs16", "\u2764\ufe0f", 2},
+		{"keycap", "#\ufe0f\u20e3", 2},
+	}
+
+	for _, tt := range tests {
+		if got := ClusterWidth([]rune(tt.cluster)); got != tt.want {
+			t.Errorf("%v: ClusterWidth(%q) = %v, want %v", tt.name, tt.cluster, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutMixedScripts(t *testing.T) {
+	tests := []struct {
+		name  string
+		html  string
+		width int
+		want  string
+	}{
+		{"cjk wrapped by cells", "中文 中文", 5, "中文\n中文\n"},
+		{"cjk broken by force", "中文中文", 5, "中文\n中文\n"},
+		{"wide cluster not split", "a中", 2, "a\n中\n"},
+		{"skin tones kept with base", "\U0001f44d\U0001f3fd\U0001f44d\U0001f3fd\U0001f44d\U0001f3fd", 4,
+			"\U0001f44d\U0001f3fd\U0001f44d\U0001f3fd\n\U0001f44d\U0001f3fd\n"},
+		{"zwj sequence kept whole", "ab \U0001f468\u200d\U0001f469\u200d\U0001f467", 3,
+			"ab\n\U0001f468\u200d\U0001f469\u200d\U0001f467\n"},
+		{"combining marks take no cells", "e\u0301e\u0301e\u0301 abc", 3, "e\u0301e\u0301e\u0301\nabc\n"},
+		{"vs16 widens symbol", "\u2764\ufe0f\u2764\ufe0f ab", 4, "\u2764\ufe0f\u2764\ufe0f\nab\n"},
+		{"mixed scripts", "Привет 世界 \U0001f44b\U0001f3fb", 9, "Привет\n世界 \U0001f44b\U0001f3fb\n"},
+	}
+
+	for _, tt := range tests {
+		txt := Parse(tt.html).Layout(Options{Width: tt.width})
+		if got := txt.String(); got != tt.want {
+			t.Errorf("%v: layout = %q, want %q", tt.name, got, tt.want)
+		}
+		for i, l := range txt.Lines {
+			if l.Indent+l.Width > tt.width {
+				t.Errorf("%v: line %v is %v cells wide, more than %v", tt.name, i, l.Indent+l.Width, tt.width)
+			}
+		}
+	}
+}
+
+func TestDrawMixedScripts(t *testing.T) {
+	// every cell is main rune with combining runes, cells covered by
+	// the right half of a wide cluster are empty
+	tests := []struct {
+		name  string
+		html  string
+		width int
+		want  []string
+	}{
+		{"cjk", "中文", 5, []string{"中", "", "文", "", " "}},
+		{"skin tone", "\U0001f44d\U0001f3fd!", 4, []string{"\U0001f44d\U0001f3fd", "", "!", " "}},
+		{"zwj", "\U0001f468\u200d\U0001f469\u200d\U0001f467", 3,
+			[]string{"\U0001f468\u200d\U0001f469\u200d\U0001f467", "", " "}},
+		{"combining", "e\u0301x", 3, []string{"e\u0301", "x", " "}},
+		// tcell measures the cluster by its narrow base rune, so the second
+		// cell stays blank, text after the cluster still starts after two cells
+		{"vs16", "\u2764\ufe0fx", 4, []string{"\u2764\ufe0f", " ", "x", " "}},
+		{"wide cluster at the edge is not drawn", "ab中", 3, []string{"a", "b", " "}},
+	}
+
+	for _, tt := range tests {
+		screen := tcell.NewSimulationScreen("UTF-8")
+		if err := screen.Init(); err != nil {
+			t.Fatal(err)
+		}
+		screen.SetSize(tt.width, 1)
+
+		// layout is wider than the screen to check clipping
+		txt := Parse(tt.html).Layout(Options{Width: 80})
+		txt.Draw(screen, 0, 0, tt.width, 1, 0, DrawOptions{Styles: DefaultStyles})
+		screen.Show()
+
+		cells, w, _ := screen.GetContents()
+		for x := 0; x < w && x < len(tt.want); x++ {
+			if got := string(cells[x].Runes); got != tt.want[x] {
+				t.Errorf("%v: cell %v = %q, want %q", tt.name, x, got, tt.want[x])
+			}
+		}
+		screen.Fini()
+	}
+}
@@ -0,0 +1,82 @@
+package richtext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Letters used by hyphenation rules
+const (
+	vowels         = "аеёиоуыэюяaeiouy"
+	specialLetters = "йьъ" // letters never starting a syllable
+)
+
+// longer tokens are not words but urls, spam and such, they aren't hyphenated
+const maxHyphenatedLength = 40
+
+// hyphenPoints returns grapheme cluster offsets where word may be hyphenated.
+// Simplified rules common for russian and english are used: both parts must
+// have a vowel and at least two letters, syllable can't start with й, ь or ъ,
+// break is made after vowel followed by consonant and vowel, between two
+// consonants or after й, ь, ъ
+func hyphenPoints(letters []rune) []int {
+	isLetter := func(i int) bool { return unicode.IsLetter(letters[i]) }
+	isVowel := func(i int) bool { return strings.ContainsRune(vowels, letters[i]) }
+	isSpecial := func(i int) bool { return strings.ContainsRune(specialLetters, letters[i]) }
+
+	// number of letters and vowels before every position
+	lettersBefore := make([]int, len(letters)+1)
+	vowelsBefore := make([]int, len(letters)+1)
+	for i := range letters {
+		lettersBefore[i+1], vowelsBefore[i+1] = lettersBefore[i], vowelsBefore[i]
+		if isLetter(i) {
+			lettersBefore[i+1]++
+		}
+		if isVowel(i) {
+			vowelsBefore[i+1]++
+		}
+	}
+
+	var points []int
+	for i := 1; i < len(letters); i++ {
+		if !isLetter(i-1) || !isLetter(i) || isSpecial(i) {
+			continue
+		}
+
+		total, totalVowels := lettersBefore[len(letters)], vowelsBefore[len(letters)]
+		if lettersBefore[i] < 2 || total-lettersBefore[i] < 2 ||
+			vowelsBefore[i] == 0 || totalVowels-vowelsBefore[i] == 0 {
+			continue
+		}
+
+		switch {
+		case isSpecial(i - 1):
+		case isVowel(i-1) && !isVowel(i) && i+1 < len(letters) && isVowel(i+1):
+		case !isVowel(i-1) && !isVowel(i):
+		default:
+			continue
+		}
+
+		points = append(points, i)
+	}
+
+	return points
+}
+
+// hyphenate returns end of the longest part of clusters starting from from
+// which may be hyphenated so the part with hyphen fits into width
+func (c *clusters) hyphenate(from, width int) (int, bool) {
+	if c.len()-from > maxHyphenatedLength {
+		return 0, false
+	}
+
+	points := hyphenPoints(c.letters[from:])
+	for i := len(points) - 1; i >= 0; i-- {
+		to := from + points[i]
+		if c.width(from, to)+1 <= width {
+			return to, true
+		}
+	}
+
+	return 0, false
+}
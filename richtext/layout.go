@@ -0,0 +1,388 @@
+package richtext
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+)
+
+// Options of layout
+type Options struct {
+	Width     int
+	Hyphenate bool // hyphenate words on wrap
+	Justify   bool // stretch wrapped lines to full width
+}
+
+// Line keeps single text line with width not more that specified
+type Line struct {
+	Runs    []Run
+	Width   int      // width of runs
+	Indent  int      // width of blank space before first run
+	Anchors []string // names of anchors placed on the line
+}
+
+// Text keeps document laid out to lines
+type Text struct {
+	Options
+	Lines []Line
+	Links Links
+}
+
+// indentStep is indentation of nested block
+const indentStep = 2
+
+// clusters keeps grapheme clusters of a run so long runs are split
+// across lines without segmenting their text again
+type clusters struct {
+	text    string
+	offsets []int  // byte offsets of clusters and length of text at the end
+	widths  []int  // widths[i] is width of clusters before i
+	letters []rune // lowercased base runes of clusters
+}
+
+func newClusters(text string) *clusters {
+	c := &clusters{text: text, widths: []int{0}}
+
+	gr := uniseg.NewGraphemes(text)
+	for gr.Next() {
+		from, _ := gr.Positions()
+		runes := gr.Runes()
+
+		c.offsets = append(c.offsets, from)
+		c.widths = append(c.widths, c.widths[len(c.widths)-1]+ClusterWidth(runes))
+		c.letters = append(c.letters, unicode.ToLower(runes[0]))
+	}
+	c.offsets = append(c.offsets, len(text))
+
+	return c
+}
+
+// len returns number of clusters
+func (c *clusters) len() int {
+	return len(c.letters)
+}
+
+// width returns width of clusters from from to to
+func (c *clusters) width(from, to int) int {
+	return c.widths[to] - c.widths[from]
+}
+
+// slice returns text of clusters from from to to
+func (c *clusters) slice(from, to int) string {
+	return c.text[c.offsets[from]:c.offsets[to]]
+}
+
+// fit returns end of the longest part of clusters starting from from
+// which fits into width, the part has at least one cluster
+func (c *clusters) fit(from, width int) int {
+	to := from + 1
+	for to < c.len() && c.width(from, to+1) <= width {
+		to++
+	}
+
+	return to
+}
+
+// trim removes trailing spaces from line
+func (l *Line) trim() {
+	for len(l.Runs) > 0 && l.Runs[len(l.Runs)-1].Kind == RunSpace {
+		l.Width -= l.Runs[len(l.Runs)-1].Width
+		l.Runs = l.Runs[:len(l.Runs)-1]
+	}
+}
+
+// hasText reports whether runs have a text run
+func hasText(runs []Run) bool {
+	for _, r := range runs {
+		if r.Kind == RunText {
+			return true
+		}
+	}
+	return false
+}
+
+// justify stretches spaces between words so line fills width,
+// leading spaces of the line are left as is
+func (l *Line) justify(width int) {
+	extra := width - l.Indent - l.Width
+	if extra <= 0 {
+		return
+	}
+
+	var gaps []int
+	for i, r := range l.Runs {
+		if r.Kind == RunSpace && i > 0 && l.Runs[i-1].Kind != RunSpace {
+			gaps = append(gaps, i)
+		}
+	}
+
+	if len(gaps) == 0 {
+		return
+	}
+
+	for n, i := range gaps {
+		add := extra / len(gaps)
+		if n < extra%len(gaps) {
+			add++
+		}
+
+		l.Runs[i].Text += strings.Repeat(" ", add)
+		l.Runs[i].Width += add
+		l.Width += add
+	}
+}
+
+// Layout lays out document to lines. Words are wrapped at spaces or
+// hyphenated, words wider than line are broken by force. Wrapped lines
+// keep indentation of leading spaces of their paragraph
+func (d *Document) Layout(opts Options) *Text {
+	txt := &Text{Options: opts, Links: d.Links}
+
+	var currLine Line
+
+	// indentation steps of opened nested blocks
+	var indents []int
+	indent := 0
+
+	// width of leading spaces of current paragraph
+	paraIndent := 0
+	lineWrapped := false
+
+	appendLine := func() {
+		txt.Lines = append(txt.Lines, currLine)
+		currLine = Line{Indent: indent}
+	}
+
+	// breakLine ends paragraph line
+	breakLine := func() {
+		appendLine()
+		paraIndent = 0
+		lineWrapped = false
+	}
+
+	// wrapLine moves rest of paragraph to the next line
+	wrapLine := func() {
+		currLine.trim()
+		if opts.Justify {
+			currLine.justify(opts.Width)
+		}
+
+		appendLine()
+		currLine.Indent += paraIndent
+		lineWrapped = true
+	}
+
+	appendRun := func(r Run) {
+		currLine.Runs = append(currLine.Runs, r)
+		currLine.Width += r.Width
+	}
+
+	setIndent := func(i int) {
+		indent = i
+		if len(currLine.Runs) == 0 {
+			currLine.Indent = indent
+		}
+	}
+
+	layoutSpace := func(r Run) {
+		if len(currLine.Runs) == 0 {
+			if lineWrapped {
+				return
+			}
+			if paraIndent+r.Width <= opts.Width/2 {
+				paraIndent += r.Width
+			}
+		}
+
+		// spaces are never moved to the next line
+		if currLine.Indent+currLine.Width+r.Width > opts.Width {
+			wrapLine()
+			return
+		}
+		appendRun(r)
+	}
+
+	layoutText := func(r Run) {
+		// clusters of the run, segmented only if the run doesn't fit
+		var cl *clusters
+		from := 0
+
+		part := func(to int, suffix string) Run {
+			p := r
+			p.Text = cl.slice(from, to) + suffix
+			p.Width = cl.width(from, to) + len(suffix)
+			return p
+		}
+
+		for {
+			space := opts.Width - currLine.Indent - currLine.Width
+
+			rest := r
+			if cl != nil {
+				rest = part(cl.len(), "")
+			}
+			if rest.Width <= space {
+				appendRun(rest)
+				return
+			}
+
+			if cl == nil {
+				cl = newClusters(r.Text)
+			}
+
+			if opts.Hyphenate {
+				if to, ok := cl.hyphenate(from, space); ok {
+					appendRun(part(to, "-"))
+					wrapLine()
+					from = to
+					continue
+				}
+			}
+
+			if len(currLine.Runs) > 0 {
+				// the run may continue a word split by markup, like a comma
+				// after a link, then the whole word goes to the next line
+				start := len(currLine.Runs)
+				for start > 0 && currLine.Runs[start-1].Kind == RunText {
+					start--
+				}
+
+				var word []Run
+				if start > 0 && start < len(currLine.Runs) && hasText(currLine.Runs[:start]) {
+					word = append(word, currLine.Runs[start:]...)
+					for _, w := range word {
+						currLine.Width -= w.Width
+					}
+					currLine.Runs = currLine.Runs[:start]
+				}
+
+				wrapLine()
+				for _, w := range word {
+					appendRun(w)
+				}
+				continue
+			}
+
+			// run is wider than the whole line, break it by force
+			to := cl.fit(from, space)
+			// cluster wider than space left by indentation is moved left
+			if w := cl.width(from, to); w > space && w <= opts.Width {
+				currLine.Indent = opts.Width - w
+			}
+			appendRun(part(to, ""))
+			if to == cl.len() {
+				return
+			}
+			wrapLine()
+			from = to
+		}
+	}
+
+	for _, r := range d.Runs {
+		switch r.Kind {
+		case RunText:
+			layoutText(r)
+
+		case RunSpace:
+			layoutSpace(r)
+
+		case RunBreak:
+			breakLine()
+
+		case RunParagraph:
+			if len(currLine.Runs) > 0 {
+				breakLine()
+			}
+
+		case RunIndent:
+			if len(currLine.Runs) > 0 {
+				breakLine()
+			}
+
+			// keep at least half of width for text
+			step := indentStep
+			if indent+step > opts.Width/2 {
+				step = 0
+			}
+			indents = append(indents, step)
+			setIndent(indent + step)
+
+		case RunAnchor:
+			currLine.Anchors = append(currLine.Anchors, r.Text)
+
+		case RunDedent:
+			if len(currLine.Runs) > 0 {
+				breakLine()
+			}
+
+			if len(indents) > 0 {
+				setIndent(indent - indents[len(indents)-1])
+				indents = indents[:len(indents)-1]
+			}
+		}
+	}
+
+	// flush last text remains
+	breakLine()
+
+	return txt
+}
+
+// LinkLine returns number of the first line containing link ref or -1
+func (txt *Text) LinkLine(ref int) int {
+	for i, l := range txt.Lines {
+		for _, r := range l.Runs {
+			if r.Style.Link == ref {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// AnchorLine returns number of the line with anchor name or -1
+func (txt *Text) AnchorLine(name string) int {
+	for i, l := range txt.Lines {
+		for _, a := range l.Anchors {
+			if a == name {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// LinkAt returns index of link at column col of line, 0 if there is no link
+func (txt *Text) LinkAt(line, col int) int {
+	if line < 0 || line >= len(txt.Lines) {
+		return 0
+	}
+
+	x := txt.Lines[line].Indent
+	for _, r := range txt.Lines[line].Runs {
+		if col >= x && col < x+r.Width {
+			return r.Style.Link
+		}
+		x += r.Width
+	}
+
+	return 0
+}
+
+// String returns text as plain text with lines separated by newlines
+func (txt *Text) String() string {
+	var sb strings.Builder
+
+	for _, l := range txt.Lines {
+		sb.WriteString(strings.Repeat(" ", l.Indent))
+		for _, r := range l.Runs {
+			sb.WriteString(r.Text)
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
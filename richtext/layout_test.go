@@ -0,0 +1,99 @@
+package richtext
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// goldenOptions are layouts written to golden files for every input
+var goldenOptions = []Options{
+	{Width: 80},
+	{Width: 40},
+	{Width: 24, Hyphenate: true},
+	{Width: 24, Hyphenate: true, Justify: true},
+}
+
+func goldenLayout(source string) string {
+	var sb strings.Builder
+
+	doc := Parse(source)
+	for _, opts := range goldenOptions {
+		fmt.Fprintf(&sb, "--- %+v\n", opts)
+		sb.WriteString(doc.Layout(opts).String())
+	}
+
+	return sb.String()
+}
+
+func TestLayoutGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no inputs in testdata")
+	}
+
+	for _, input := range inputs {
+		source, err := ioutil.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := goldenLayout(string(source))
+		golden := strings.TrimSuffix(input, ".html") + ".golden"
+		if *update {
+			if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%v, run go test -update to create it", err)
+		}
+		if got != string(want) {
+			t.Errorf("%v: layout differs from %v:\n%v", input, golden, got)
+		}
+	}
+}
+
+// BenchmarkLayout lays out a thread of 500 posts made of testdata inputs
+func BenchmarkLayout(b *testing.B) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil || len(inputs) == 0 {
+		b.Fatal("no inputs in testdata")
+	}
+
+	var sources []string
+	for _, input := range inputs {
+		source, err := ioutil.ReadFile(input)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sources = append(sources, string(source))
+	}
+
+	docs := make([]*Document, 500)
+	for i := range docs {
+		docs[i] = Parse(sources[i%len(sources)])
+	}
+
+	for _, opts := range []Options{{Width: 80}, {Width: 80, Hyphenate: true, Justify: true}} {
+		b.Run(fmt.Sprintf("hyphenate=%v,justify=%v", opts.Hyphenate, opts.Justify), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, doc := range docs {
+					doc.Layout(opts)
+				}
+			}
+		})
+	}
+}
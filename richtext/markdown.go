@@ -0,0 +1,112 @@
+package richtext
+
+import "strings"
+
+// markdownMarks are Markdown delimiters of attributes in order of nesting
+var markdownMarks = []struct {
+	attr Attr
+	mark string
+}{
+	{AttrBold, "**"},
+	{AttrItalic, "_"},
+	{AttrStrikethrough, "~~"},
+}
+
+// markdownEscaper escapes characters having meaning in Markdown inline text.
+// '>' is kept as is, so greentext lines become quotes
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "[", `\[`, "]", `\]`,
+)
+
+// Markdown returns document as Markdown. Bold, italic and strikethrough
+// text and links are kept, other attributes are dropped, nested blocks
+// become paragraphs
+func (d *Document) Markdown() string {
+	var sb strings.Builder
+	// spaces are written after delimiters closing styles and before opening
+	// ones, otherwise Markdown doesn't recognize the delimiters
+	var spaces strings.Builder
+	// attributes in order of opening their delimiters
+	var open []Attr
+	link := 0
+
+	// closeAttrs closes delimiters of attributes not in attrs and all opened
+	// after them
+	closeAttrs := func(attrs Attr) {
+		for i, a := range open {
+			if attrs&a == 0 {
+				for j := len(open) - 1; j >= i; j-- {
+					for _, m := range markdownMarks {
+						if m.attr == open[j] {
+							sb.WriteString(m.mark)
+						}
+					}
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+	openAttrs := func(attrs Attr) {
+		for _, m := range markdownMarks {
+			if attrs&m.attr == 0 {
+				continue
+			}
+
+			opened := false
+			for _, a := range open {
+				opened = opened || a == m.attr
+			}
+			if !opened {
+				sb.WriteString(m.mark)
+				open = append(open, m.attr)
+			}
+		}
+	}
+	closeLink := func() {
+		closeAttrs(0)
+		if link != 0 {
+			sb.WriteString("](" + d.Links[link].URL + ")")
+			link = 0
+		}
+	}
+
+	for _, r := range d.Runs {
+		switch r.Kind {
+		case RunSpace:
+			spaces.WriteString(r.Text)
+
+		case RunText:
+			st := r.Style
+			st.Attrs &= AttrBold | AttrItalic | AttrStrikethrough
+
+			if st.Link != link {
+				closeLink()
+			} else {
+				closeAttrs(st.Attrs)
+			}
+			sb.WriteString(spaces.String())
+			spaces.Reset()
+
+			if st.Link != link {
+				sb.WriteString("[")
+				link = st.Link
+			}
+			openAttrs(st.Attrs)
+			sb.WriteString(markdownEscaper.Replace(r.Text))
+
+		case RunBreak:
+			closeLink()
+			spaces.Reset()
+			sb.WriteString("  \n")
+
+		case RunParagraph, RunIndent, RunDedent:
+			closeLink()
+			spaces.Reset()
+			sb.WriteString("\n\n")
+		}
+	}
+	closeLink()
+
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,347 @@
+package richtext
+
+import (
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/net/html"
+)
+
+// TagAttr is an attribute of HTML tag
+type TagAttr struct {
+	Name  string
+	Value string
+}
+
+// ParseHTML Parse HTML and emit tokens, entities in text and attributes are decoded.
+// Malformed markup is tokenized the way browsers do it, parsing stops on the
+// first tokenizer error which is returned
+func ParseHTML(source string, eventFunc func(tt html.TokenType, token string, attrs []TagAttr)) error {
+	tokenizer := html.NewTokenizer(strings.NewReader(source))
+
+	for {
+		tokenType := tokenizer.Next()
+
+		switch tokenType {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return nil
+			}
+			return tokenizer.Err()
+
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			tagName, hasAttrs := tokenizer.TagName()
+			tagNameStr := string(tagName)
+
+			if tokenType != html.EndTagToken {
+				var attrs []TagAttr
+
+				if hasAttrs {
+					for {
+						key, value, more := tokenizer.TagAttr()
+
+						attrs = append(attrs, TagAttr{string(key), string(value)})
+						if !more {
+							break
+						}
+					}
+				}
+
+				// <br/> is handled the same way as <br>
+				eventFunc(html.StartTagToken, tagNameStr, attrs)
+
+			} else {
+				// attributes of end tags are meaningless and skipped
+				eventFunc(tokenType, tagNameStr, nil)
+			}
+
+		case html.TextToken:
+			eventFunc(tokenType, string(tokenizer.Text()), nil)
+		}
+	}
+}
+
+// local links look like /b/res/123.html#456
+var localLinkRegexp = regexp.MustCompile(`^/(\w+)/res/(\d+)\.html(?:#(\d+))?$`)
+
+// ParseLink fills Link from attributes of <a> tag
+func ParseLink(attrs []TagAttr) Link {
+	var l Link
+
+	parseNum := func(s string) int64 {
+		num, _ := strconv.ParseInt(s, 10, 64)
+		return num
+	}
+
+	for _, attr := range attrs {
+		switch attr.Name {
+		case "href":
+			l.URL = attr.Value
+		case "data-thread":
+			l.Thread = parseNum(attr.Value)
+		case "data-num":
+			l.Post = parseNum(attr.Value)
+		}
+	}
+
+	if m := localLinkRegexp.FindStringSubmatch(l.URL); m != nil {
+		l.Local = true
+		l.Board = m[1]
+		if l.Thread == 0 {
+			l.Thread = parseNum(m[2])
+		}
+		if l.Post == 0 {
+			l.Post = l.Thread
+			if m[3] != "" {
+				l.Post = parseNum(m[3])
+			}
+		}
+	}
+
+	return l
+}
+
+// anchorName returns name of <a> tag without href
+func anchorName(attrs []TagAttr) (string, bool) {
+	var name string
+	for _, attr := range attrs {
+		switch attr.Name {
+		case "href":
+			return "", false
+		case "name", "id":
+			name = attr.Value
+		}
+	}
+
+	return name, name != ""
+}
+
+// tagClasses returns list of classes from attributes of tag
+func tagClasses(attrs []TagAttr) []string {
+	var classes []string
+	for _, attr := range attrs {
+		if attr.Name == "class" {
+			classes = append(classes, strings.Fields(attr.Value)...)
+		}
+	}
+
+	return classes
+}
+
+// superscript and subscript variants of runes, runes without them are left as is
+var (
+	superscriptRunes = map[rune]rune{
+		'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+		'+': '⁺', '-': '⁻', '=': '⁼', '(': '⁽', ')': '⁾', 'n': 'ⁿ', 'i': 'ⁱ',
+	}
+	subscriptRunes = map[rune]rune{
+		'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+		'+': '₊', '-': '₋', '=': '₌', '(': '₍', ')': '₎', 'a': 'ₐ', 'e': 'ₑ', 'o': 'ₒ', 'x': 'ₓ',
+	}
+)
+
+// scriptRune returns rune as superscript or subscript according to style
+func (st Style) scriptRune(ch rune) rune {
+	var runes map[rune]rune
+
+	switch {
+	case st.Attrs&AttrSuperscript != 0:
+		runes = superscriptRunes
+	case st.Attrs&AttrSubscript != 0:
+		runes = subscriptRunes
+	default:
+		return ch
+	}
+
+	if r, ok := runes[ch]; ok {
+		return r
+	}
+	return ch
+}
+
+// spanAttrs are attributes of 2ch markup classes of <span>
+var spanAttrs = map[string]Attr{
+	"unkfunc": AttrGreentext, // greentext quote
+	"spoiler": AttrSpoiler,
+	"s":       AttrStrikethrough,
+	"u":       AttrUnderline,
+	"o":       AttrOverline,
+
+	// classes of markup generated by client
+	"post-header": AttrHeader,
+	"post-op":     AttrOPMark,
+	"unread-mark": AttrUnread,
+}
+
+// tagAttrs are attributes of styling tags
+var tagAttrs = map[string]Attr{
+	"strong": AttrBold,
+	"b":      AttrBold,
+	"em":     AttrItalic,
+	"i":      AttrItalic,
+	"sup":    AttrSuperscript,
+	"sub":    AttrSubscript,
+}
+
+// Parse parses HTML of a comment into document. Text is split into words
+// and spaces, 2ch markup tags are converted to attributes. Comments come
+// from users, so any input is accepted: unbalanced end tags are ignored,
+// misnested ones close all tags opened after the matching start tag
+func Parse(source string) *Document {
+	doc := &Document{Links: make(Links)}
+
+	var currRun Run
+	var currText strings.Builder
+	style := []Style{{}}
+	// tags which opened styles of the stack
+	styleTags := []string{""}
+
+	currStyle := func() Style {
+		return style[len(style)-1]
+	}
+
+	flushRun := func() {
+		currRun.Text = currText.String()
+		currText.Reset()
+
+		if currRun.Text != "" {
+			doc.Runs = append(doc.Runs, currRun)
+
+			if ref := currRun.Style.Link; ref != 0 {
+				link := doc.Links[ref]
+				link.Text += currRun.Text
+				doc.Links[ref] = link
+			}
+		}
+
+		currRun = Run{Style: currStyle()}
+	}
+
+	pushStyle := func(tag string, st Style) {
+		flushRun()
+		style = append(style, st)
+		styleTags = append(styleTags, tag)
+		currRun.Style = st
+	}
+
+	popStyle := func(tag string) {
+		flushRun()
+
+		for i := len(styleTags) - 1; i > 0; i-- {
+			if styleTags[i] == tag {
+				style = style[:i]
+				styleTags = styleTags[:i]
+				break
+			}
+		}
+
+		currRun.Style = currStyle()
+	}
+
+	command := func(kind RunKind) {
+		flushRun()
+		doc.Runs = append(doc.Runs, Run{Kind: kind})
+	}
+
+	splitText := func(text string) {
+		gr := uniseg.NewGraphemes(text)
+		for gr.Next() {
+			cluster := gr.Runes()
+
+			kind := RunText
+			if cluster[0] == ' ' {
+				kind = RunSpace
+			}
+			if kind != currRun.Kind {
+				flushRun()
+				currRun.Kind = kind
+			}
+
+			if len(cluster) == 1 {
+				cluster[0] = currStyle().scriptRune(cluster[0])
+			}
+
+			for _, r := range cluster {
+				currText.WriteRune(r)
+			}
+			currRun.Width += ClusterWidth(cluster)
+		}
+
+		flushRun()
+	}
+
+	eventFunc := func(tt html.TokenType, token string, attrs []TagAttr) {
+		switch tt {
+		case html.StartTagToken:
+			switch token {
+			case "br":
+				command(RunBreak)
+
+			case "p":
+				command(RunParagraph)
+
+			case "blockquote":
+				command(RunIndent)
+
+			case "a":
+				st := currStyle()
+				if name, ok := anchorName(attrs); ok {
+					// named anchor without href is a position, not a link
+					flushRun()
+					doc.Runs = append(doc.Runs, Run{Kind: RunAnchor, Text: name})
+				} else {
+					st.Link = len(doc.Links) + 1
+					doc.Links[st.Link] = ParseLink(attrs)
+				}
+				pushStyle(token, st)
+
+			case "span":
+				st := currStyle()
+				for _, class := range tagClasses(attrs) {
+					st.Attrs |= spanAttrs[class]
+				}
+				pushStyle(token, st)
+
+			default:
+				if attr, ok := tagAttrs[token]; ok {
+					st := currStyle()
+					st.Attrs |= attr
+					pushStyle(token, st)
+				} else {
+					flushRun()
+				}
+			}
+
+		case html.EndTagToken:
+			switch token {
+			case "p":
+				command(RunParagraph)
+
+			case "blockquote":
+				command(RunDedent)
+
+			case "a", "span":
+				popStyle(token)
+
+			default:
+				if _, ok := tagAttrs[token]; ok {
+					popStyle(token)
+				} else {
+					flushRun()
+				}
+			}
+
+		case html.TextToken:
+			splitText(token)
+		}
+	}
+
+	// on tokenizer error the text parsed so far is kept
+	ParseHTML(source, eventFunc)
+	flushRun()
+
+	return doc
+}
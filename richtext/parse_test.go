@@ -0,0 +1,70 @@
+package richtext
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, input := range inputs {
+		source, err := ioutil.ReadFile(input)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(source))
+	}
+
+	// unclosed, misnested and broken tags
+	for _, source := range []string{
+		"",
+		"<strong>жирный",
+		"</em>лишний конец</span></a>",
+		"<strong><em>текст</strong></em>",
+		"<span class=\"spoiler\"><a href=\"/b/res/1.html#2\" data-num=\"2\">&gt;&gt;2</span></a>",
+		"<blockquote><blockquote><blockquote>вложенные цитаты",
+		"</blockquote></blockquote>текст<blockquote>",
+		"<a name=\"top\">якорь</a><a href=\"#top\">наверх</a>",
+		"<sup><sub>x</sup>2</sub>",
+		"<span class=\"unkfunc\"&gt;цитата",
+		"<a href=\"/b/res/1.html\" data-num=\"abc\">",
+		"текст <br/><br><p></p> &amp &lt;&#x41;&#999999999;",
+		"<",
+		"<a",
+		"<!-- комментарий",
+		"中文❤️\U0001f468‍\U0001f469‍\U0001f467é́",
+		"    отступ абзаца",
+		"Достопримечательности<em>высокопревосходительство</em>,",
+	} {
+		f.Add(source)
+	}
+
+	f.Fuzz(func(t *testing.T, source string) {
+		doc := Parse(source)
+		_ = doc.Markdown()
+		_ = doc.PlainText()
+
+		// widths start from the widest cluster
+		for _, width := range []int{2, 5, 20, 80} {
+			for _, opts := range []Options{
+				{Width: width},
+				{Width: width, Hyphenate: true},
+				{Width: width, Justify: true},
+				{Width: width, Hyphenate: true, Justify: true},
+			} {
+				txt := doc.Layout(opts)
+				_ = txt.String()
+
+				for i, l := range txt.Lines {
+					if l.Indent+l.Width > width {
+						t.Fatalf("%+v: line %v is %v cells wide: %q", opts, i, l.Indent+l.Width, txt.String())
+					}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package richtext
+
+import "strings"
+
+// PlainText returns text of the document in one line: line breaks and
+// paragraphs become spaces, repeated spaces are collapsed
+func (d *Document) PlainText() string {
+	var sb strings.Builder
+
+	space := false
+	for _, r := range d.Runs {
+		switch r.Kind {
+		case RunText:
+			if space && sb.Len() > 0 {
+				sb.WriteByte(' ')
+			}
+			space = false
+			sb.WriteString(r.Text)
+		case RunSpace, RunBreak, RunParagraph, RunIndent, RunDedent:
+			space = true
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,78 @@
+// Package richtext converts HTML of 2ch comments into styled text laid out
+// for a terminal: HTML is parsed into a Document of styled runs, the Document
+// is laid out to lines of given width, the resulting Text can be drawn on
+// tcell screen, queried for links or printed as plain text.
+package richtext
+
+// Attr is a set of text attributes from markup
+type Attr uint16
+
+// Text attributes
+const (
+	AttrBold Attr = 1 << iota
+	AttrItalic
+	AttrUnderline
+	AttrStrikethrough
+	AttrOverline
+	AttrGreentext
+	AttrSpoiler
+	AttrSuperscript
+	AttrSubscript
+	AttrHeader // post header
+	AttrOPMark // mark of thread author
+	AttrUnread // divider of unread posts
+)
+
+// Style keeps attributes of a run and link it belongs to
+type Style struct {
+	Attrs Attr
+	Link  int // index in Links, 0 outside of links
+}
+
+// RunKind is a type of run
+type RunKind int
+
+// Kinds of runs
+const (
+	// RunText is a word or its part
+	RunText RunKind = iota
+	// RunSpace is spaces between words
+	RunSpace
+	// RunBreak ends line
+	RunBreak
+	// RunParagraph ends line if it is not empty
+	RunParagraph
+	// RunIndent starts nested block
+	RunIndent
+	// RunDedent ends nested block
+	RunDedent
+	// RunAnchor marks position in text, Text is name of the anchor
+	RunAnchor
+)
+
+// Run is a piece of text with single style or a layout command
+type Run struct {
+	Kind  RunKind
+	Text  string
+	Width int // width of text in screen cells
+	Style Style
+}
+
+// Link keeps information about link
+type Link struct {
+	Text   string
+	URL    string
+	Local  bool   // link to a post on 2ch
+	Board  string // board of the local link
+	Thread int64
+	Post   int64
+}
+
+// Links map of links, key is link index starting from 1 in order of appearance
+type Links map[int]Link
+
+// Document is HTML parsed into runs
+type Document struct {
+	Runs  []Run
+	Links Links
+}
@@ -0,0 +1,196 @@
+package stem
+
+import "strings"
+
+// English Porter stemmer, https://tartarus.org/martin/PorterStemmer/def.txt
+
+// porterWord is a word being stemmed, j is the end of the stem preceding the
+// suffix checked last
+type porterWord struct {
+	b []byte
+	j int
+}
+
+// cons reports whether b[i] is a consonant
+func (w *porterWord) cons(i int) bool {
+	switch w.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !w.cons(i-1)
+	}
+	return true
+}
+
+// m measures number of consonant sequences in b[:j]: <c><v> gives 0,
+// <c>vc<v> gives 1, <c>vcvc<v> gives 2 and so on
+func (w *porterWord) m() int {
+	n, i := 0, 0
+	for ; i < w.j && w.cons(i); i++ {
+	}
+	for i < w.j {
+		for ; i < w.j && !w.cons(i); i++ {
+		}
+		if i >= w.j {
+			break
+		}
+		n++
+		for ; i < w.j && w.cons(i); i++ {
+		}
+	}
+	return n
+}
+
+// vowelInStem reports whether b[:j] contains a vowel
+func (w *porterWord) vowelInStem() bool {
+	for i := 0; i < w.j; i++ {
+		if !w.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doublec reports whether b[i-1:i+1] is a double consonant
+func (w *porterWord) doublec(i int) bool {
+	return i >= 1 && w.b[i] == w.b[i-1] && w.cons(i)
+}
+
+// cvc reports whether b[i-2:i+1] is consonant-vowel-consonant and the last
+// consonant is not w, x or y
+func (w *porterWord) cvc(i int) bool {
+	if i < 2 || !w.cons(i) || w.cons(i-1) || !w.cons(i-2) {
+		return false
+	}
+	switch w.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends reports whether word ends with s and sets j to the start of s
+func (w *porterWord) ends(s string) bool {
+	if !strings.HasSuffix(string(w.b), s) {
+		return false
+	}
+	w.j = len(w.b) - len(s)
+	return true
+}
+
+// setTo replaces the suffix after j with s
+func (w *porterWord) setTo(s string) {
+	w.b = append(w.b[:w.j], s...)
+}
+
+// suffix and replacement pairs of steps 2 and 3, removed suffixes of step 4
+var (
+	step2Suffixes = []string{
+		"ational", "ate", "tional", "tion", "enci", "ence", "anci", "ance", "izer", "ize", "bli", "ble",
+		"alli", "al", "entli", "ent", "eli", "e", "ousli", "ous", "ization", "ize", "ation", "ate", "ator", "ate",
+		"alism", "al", "iveness", "ive", "fulness", "ful", "ousness", "ous", "aliti", "al", "iviti", "ive",
+		"biliti", "ble", "logi", "log",
+	}
+	step3Suffixes = []string{
+		"icate", "ic", "ative", "", "alize", "al", "iciti", "ic", "ical", "ic", "ful", "", "ness", "",
+	}
+	step4Suffixes = []string{
+		"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent", "ion", "ou",
+		"ism", "ate", "iti", "ous", "ive", "ize",
+	}
+)
+
+// longestEnd finds the longest suffix at the end of word among every step-th
+// element of list and sets j to its start
+func (w *porterWord) longestEnd(list []string, step int) (int, bool) {
+	best, found := -1, false
+	for i := 0; i < len(list); i += step {
+		if strings.HasSuffix(string(w.b), list[i]) && (!found || len(list[i]) > len(list[best])) {
+			best, found = i, true
+		}
+	}
+	if found {
+		w.j = len(w.b) - len(list[best])
+	}
+	return best, found
+}
+
+// English returns stem of lower case English word
+func English(s string) string {
+	if len(s) <= 2 {
+		return s
+	}
+	w := &porterWord{b: []byte(s)}
+
+	// step 1a
+	switch {
+	case w.ends("sses"):
+		w.setTo("ss")
+	case w.ends("ies"):
+		w.setTo("i")
+	case w.ends("ss"):
+	case w.ends("s"):
+		w.setTo("")
+	}
+
+	// step 1b
+	if w.ends("eed") {
+		if w.m() > 0 {
+			w.setTo("ee")
+		}
+	} else if (w.ends("ed") || w.ends("ing")) && w.vowelInStem() {
+		w.setTo("")
+		k := len(w.b) - 1
+		switch {
+		case w.ends("at"):
+			w.setTo("ate")
+		case w.ends("bl"):
+			w.setTo("ble")
+		case w.ends("iz"):
+			w.setTo("ize")
+		case w.doublec(k):
+			if c := w.b[k]; c != 'l' && c != 's' && c != 'z' {
+				w.b = w.b[:k]
+			}
+		default:
+			w.j = len(w.b)
+			if w.m() == 1 && w.cvc(k) {
+				w.b = append(w.b, 'e')
+			}
+		}
+	}
+
+	// step 1c
+	if w.ends("y") && w.vowelInStem() {
+		w.setTo("i")
+	}
+
+	// steps 2 and 3
+	for _, list := range [][]string{step2Suffixes, step3Suffixes} {
+		if i, ok := w.longestEnd(list, 2); ok && w.m() > 0 {
+			w.setTo(list[i+1])
+		}
+	}
+
+	// step 4
+	if i, ok := w.longestEnd(step4Suffixes, 1); ok && w.m() > 1 {
+		if step4Suffixes[i] != "ion" || (w.j > 0 && (w.b[w.j-1] == 's' || w.b[w.j-1] == 't')) {
+			w.setTo("")
+		}
+	}
+
+	// step 5a
+	if w.ends("e") {
+		if m := w.m(); m > 1 || (m == 1 && !w.cvc(len(w.b)-2)) {
+			w.setTo("")
+		}
+	}
+
+	// step 5b
+	w.j = len(w.b)
+	if k := len(w.b) - 1; k > 0 && w.b[k] == 'l' && w.doublec(k) && w.m() > 1 {
+		w.b = w.b[:k]
+	}
+
+	return string(w.b)
+}
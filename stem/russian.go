@@ -0,0 +1,167 @@
+package stem
+
+// Russian Snowball stemmer,
+// https://snowballstem.org/algorithms/russian/stemmer.html
+
+// ending groups, endings of the first group must follow а or я
+type endings struct {
+	afterA []string // preceded by а or я, which is kept
+	other  []string
+}
+
+var (
+	perfectiveGerund = endings{
+		afterA: []string{"в", "вши", "вшись"},
+		other:  []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"},
+	}
+	adjective = endings{
+		other: []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+			"его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"},
+	}
+	participle = endings{
+		afterA: []string{"ем", "нн", "вш", "ющ", "щ"},
+		other:  []string{"ивш", "ывш", "ующ"},
+	}
+	reflexive = endings{
+		other: []string{"ся", "сь"},
+	}
+	verb = endings{
+		afterA: []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"},
+		other: []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен",
+			"ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю"},
+	}
+	noun = endings{
+		other: []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й",
+			"иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я"},
+	}
+	derivational = endings{
+		other: []string{"ост", "ость"},
+	}
+	superlative = endings{
+		other: []string{"ейш", "ейше"},
+	}
+)
+
+func isRussianVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	}
+	return false
+}
+
+// hasSuffix reports whether word ends with suffix
+func hasSuffix(word []rune, suffix string) bool {
+	s := []rune(suffix)
+	if len(s) > len(word) {
+		return false
+	}
+	for i := range s {
+		if word[len(word)-len(s)+i] != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns length of the longest ending of group found at the end of
+// word not before position from, 0 if none. Like Snowball's among, a shorter
+// ending is not tried if the longest one doesn't satisfy its condition
+func (e endings) match(word []rune, from int) int {
+	longest, afterA := 0, false
+	for i, group := range [][]string{e.afterA, e.other} {
+		for _, suffix := range group {
+			n := len([]rune(suffix))
+			if n > longest && len(word)-n >= from && hasSuffix(word, suffix) {
+				longest, afterA = n, i == 0
+			}
+		}
+	}
+
+	if longest > 0 && afterA {
+		at := len(word) - longest - 1
+		if at < from || (word[at] != 'а' && word[at] != 'я') {
+			return 0
+		}
+	}
+
+	return longest
+}
+
+// russianRegions returns start of RV and R2 regions of word
+func russianRegions(word []rune) (rv, r2 int) {
+	rv, r1, r2 := len(word), len(word), len(word)
+
+	for i, r := range word {
+		if isRussianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+
+	for i := 1; i < len(word); i++ {
+		if !isRussianVowel(word[i]) && isRussianVowel(word[i-1]) {
+			r1 = i + 1
+			break
+		}
+	}
+
+	for i := r1 + 1; i < len(word); i++ {
+		if !isRussianVowel(word[i]) && isRussianVowel(word[i-1]) {
+			r2 = i + 1
+			break
+		}
+	}
+
+	return rv, r2
+}
+
+// Russian returns stem of lower case Russian word, ё must be replaced with е
+func Russian(s string) string {
+	word := []rune(s)
+	rv, r2 := russianRegions(word)
+
+	// step 1
+	if n := perfectiveGerund.match(word, rv); n > 0 {
+		word = word[:len(word)-n]
+	} else {
+		if n := reflexive.match(word, rv); n > 0 {
+			word = word[:len(word)-n]
+		}
+
+		if n := adjective.match(word, rv); n > 0 {
+			word = word[:len(word)-n]
+			if n := participle.match(word, rv); n > 0 {
+				word = word[:len(word)-n]
+			}
+		} else if n := verb.match(word, rv); n > 0 {
+			word = word[:len(word)-n]
+		} else if n := noun.match(word, rv); n > 0 {
+			word = word[:len(word)-n]
+		}
+	}
+
+	// step 2
+	if len(word) > rv && word[len(word)-1] == 'и' {
+		word = word[:len(word)-1]
+	}
+
+	// step 3
+	if n := derivational.match(word, r2); n > 0 {
+		word = word[:len(word)-n]
+	}
+
+	// step 4
+	if n := superlative.match(word, rv); n > 0 {
+		word = word[:len(word)-n]
+		if len(word)-2 >= rv && hasSuffix(word, "нн") {
+			word = word[:len(word)-1]
+		}
+	} else if len(word)-2 >= rv && hasSuffix(word, "нн") {
+		word = word[:len(word)-1]
+	} else if len(word) > rv && word[len(word)-1] == 'ь' {
+		word = word[:len(word)-1]
+	}
+
+	return string(word)
+}
@@ -0,0 +1,39 @@
+// Package stem reduces Russian and English words to their stems for full
+// text search, so that different forms of a word are found by each other.
+// Russian words are stemmed by the Snowball algorithm, English ones by the
+// original Porter algorithm.
+package stem
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Stem returns stem of lower case word, the language is chosen by the
+// script of the word. Words mixing scripts and numbers are returned as is
+func Stem(word string) string {
+	cyrillic, latin := true, true
+	for _, r := range word {
+		if !unicode.Is(unicode.Cyrillic, r) {
+			cyrillic = false
+		}
+		if r < 'a' || r > 'z' {
+			latin = false
+		}
+	}
+
+	switch {
+	case cyrillic:
+		return Russian(word)
+	case latin:
+		return English(word)
+	default:
+		return word
+	}
+}
+
+// Normalize converts word to the form expected by Stem: lower case, ё is
+// replaced with е
+func Normalize(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+}
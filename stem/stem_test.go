@@ -0,0 +1,84 @@
+package stem
+
+import "testing"
+
+// stemTest is a word and its expected stem
+type stemTest struct {
+	word, stem string
+}
+
+func testStems(t *testing.T, stem func(string) string, tests []stemTest) {
+	for _, tt := range tests {
+		if got := stem(tt.word); got != tt.stem {
+			t.Errorf("stem of %q = %q, want %q", tt.word, got, tt.stem)
+		}
+	}
+}
+
+func TestEnglish(t *testing.T) {
+	// examples of https://tartarus.org/martin/PorterStemmer/def.txt
+	testStems(t, English, []stemTest{
+		{"caresses", "caress"}, {"ponies", "poni"}, {"ties", "ti"}, {"caress", "caress"},
+		{"cats", "cat"}, {"feed", "feed"}, {"agreed", "agre"}, {"plastered", "plaster"},
+		{"bled", "bled"}, {"motoring", "motor"}, {"sing", "sing"}, {"conflated", "conflat"},
+		{"troubled", "troubl"}, {"sized", "size"}, {"hopping", "hop"}, {"tanned", "tan"},
+		{"falling", "fall"}, {"hissing", "hiss"}, {"fizzed", "fizz"}, {"failing", "fail"},
+		{"filing", "file"}, {"happy", "happi"}, {"sky", "sky"}, {"relational", "relat"},
+		{"conditional", "condit"}, {"rational", "ration"}, {"valenci", "valenc"}, {"digitizer", "digit"},
+		{"conformabli", "conform"}, {"radicalli", "radic"}, {"differentli", "differ"}, {"vileli", "vile"},
+		{"analogousli", "analog"}, {"vietnamization", "vietnam"}, {"predication", "predic"}, {"operator", "oper"},
+		{"feudalism", "feudal"}, {"decisiveness", "decis"}, {"hopefulness", "hope"}, {"callousness", "callous"},
+		{"formaliti", "formal"}, {"sensitiviti", "sensit"}, {"sensibiliti", "sensibl"}, {"triplicate", "triplic"},
+		{"formative", "form"}, {"formalize", "formal"}, {"electriciti", "electr"}, {"electrical", "electr"},
+		{"hopeful", "hope"}, {"goodness", "good"}, {"revival", "reviv"}, {"allowance", "allow"},
+		{"inference", "infer"}, {"airliner", "airlin"}, {"gyroscopic", "gyroscop"}, {"adjustable", "adjust"},
+		{"defensible", "defens"}, {"irritant", "irrit"}, {"replacement", "replac"}, {"adjustment", "adjust"},
+		{"dependent", "depend"}, {"adoption", "adopt"}, {"homologou", "homolog"}, {"communism", "commun"},
+		{"activate", "activ"}, {"angulariti", "angular"}, {"homologous", "homolog"}, {"effective", "effect"},
+		{"bowdlerize", "bowdler"}, {"probate", "probat"}, {"rate", "rate"}, {"cease", "ceas"},
+		{"controll", "control"}, {"roll", "roll"}, {"generalizations", "gener"}, {"oscillators", "oscil"},
+	})
+}
+
+func TestRussian(t *testing.T) {
+	// words of the Snowball vocabulary, https://snowballstem.org/algorithms/russian/stemmer.html
+	testStems(t, Russian, []stemTest{
+		{"вавиловка", "вавиловк"}, {"вагнера", "вагнер"}, {"важная", "важн"}, {"важнейшие", "важн"},
+		{"важного", "важн"}, {"вазы", "ваз"}, {"вал", "вал"}, {"валентина", "валентин"},
+		{"валялась", "валя"}, {"валяется", "валя"}, {"вам", "вам"}, {"вами", "вам"},
+		{"ваня", "ван"}, {"варвара", "варвар"}, {"варенье", "варен"}, {"вариант", "вариант"},
+		{"ваш", "ваш"}, {"вашего", "ваш"}, {"вбежал", "вбежа"}, {"вверх", "вверх"},
+		{"вдруг", "вдруг"}, {"ведь", "вед"}, {"везде", "везд"}, {"великий", "велик"},
+		{"величайшее", "величайш"}, {"вести", "вест"}, {"весь", "ве"}, {"весьма", "весьм"},
+		{"взгляд", "взгляд"}, {"видно", "видн"}, {"вместо", "вмест"}, {"вниз", "вниз"},
+		{"внимание", "вниман"}, {"вновь", "внов"}, {"вода", "вод"}, {"возвращаясь", "возвра"},
+		{"возможно", "возможн"}, {"война", "войн"}, {"вокруг", "вокруг"}, {"волнение", "волнен"},
+		{"вопрос", "вопрос"}, {"вопросы", "вопрос"}, {"восторг", "восторг"}, {"всегда", "всегд"},
+		{"вскочил", "вскоч"}, {"встретил", "встрет"}, {"вся", "вся"}, {"второй", "втор"},
+		{"вы", "вы"}, {"выйти", "выйт"}, {"высокий", "высок"}, {"газета", "газет"},
+		{"говорила", "говор"}, {"голову", "голов"}, {"господа", "господ"}, {"гостиница", "гостиниц"},
+		{"давно", "давн"}, {"дело", "дел"}, {"деньги", "деньг"}, {"день", "ден"},
+		{"думаю", "дума"}, {"ехать", "еха"}, {"жизнь", "жизн"}, {"книга", "книг"},
+		{"красивый", "красив"}, {"писать", "писа"}, {"сказал", "сказа"}, {"человек", "человек"},
+	})
+}
+
+func TestStem(t *testing.T) {
+	testStems(t, Stem, []stemTest{
+		{"вопросы", "вопрос"},
+		{"generalizations", "gener"},
+		// mixed scripts, numbers and upper case are not stemmed
+		{"gameдев", "gameдев"},
+		{"mp4", "mp4"},
+		{"Cats", "Cats"},
+		{"", ""},
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	testStems(t, Normalize, []stemTest{
+		{"Ёлки", "елки"},
+		{"ЕЩЁ", "еще"},
+		{"Cats", "cats"},
+	})
+}